@@ -0,0 +1,111 @@
+package gobucket
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxConcurrentWaitDoesNotCountLifeSpan(t *testing.T) {
+	var running, peak int32
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		if n > atomic.LoadInt32(&peak) {
+			atomic.StoreInt32(&peak, n)
+		}
+		return sleeping(100*time.Millisecond)(ctx, id, data)
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:      150 * time.Millisecond,
+		MaxBucket:     10,
+		MaxConcurrent: 1,
+	}, e)
+	ctx := context.Background()
+	var fs []*Future
+	for i := 0; i < 4; i++ {
+		f, err := tb.FillFuture(ctx, strconv.Itoa(i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs = append(fs, f)
+	}
+	for _, f := range fs {
+		res, ok := waitResult(f)
+		if !ok || res.State != StateFinished {
+			t.Fatal("task did not finish", f.ID(), res)
+		}
+	}
+	if atomic.LoadInt32(&peak) != 1 {
+		t.Fatal("more than one task ran at once", peak)
+	}
+}
+
+func TestMaxConcurrentRetryWaitDoesNotCountLifeSpan(t *testing.T) {
+	var calls int32
+	var tb TaskBucket
+	ctx := context.Background()
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		if id == "retry" && atomic.AddInt32(&calls, 1) == 1 {
+			//the retry has to wait for both of them
+			tb.Fill(ctx, ImmidiateTask, "0", nil)
+			tb.Fill(ctx, ImmidiateTask, "1", nil)
+			time.Sleep(10 * time.Millisecond)
+			return errFull
+		}
+		return sleeping(100*time.Millisecond)(ctx, id, data)
+	}
+	tb = NewTaskBucket(&BucketConfig{
+		LifeSpan:      150 * time.Millisecond,
+		MaxBucket:     10,
+		MaxConcurrent: 1,
+		Retry:         &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+	}, e)
+	f, err := tb.FillFuture(ctx, "retry", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := waitResult(f); !ok || res.State != StateFinished || res.Attempts != 2 {
+		t.Fatal("retry did not finish", res)
+	}
+}
+
+func TestMaxConcurrentWaitHoldsOneGoroutine(t *testing.T) {
+	release := make(chan struct{})
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		<-release
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:      time.Minute,
+		MaxBucket:     200,
+		MaxConcurrent: 1,
+	}, e)
+	ctx := context.Background()
+	if err := tb.Fill(ctx, ImmidiateTask, "running", nil); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		if err := tb.Fill(ctx, ImmidiateTask, strconv.Itoa(i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := runtime.NumGoroutine() - before; n > 150 {
+		t.Fatal("waiting tasks hold", n, "goroutines")
+	}
+	close(release)
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := tb.WaitIdle(wctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package gobucket
+
+import (
+	"testing"
+	"time"
+)
+
+//2024-01-01 is a Monday
+var cronStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestCronNext(t *testing.T) {
+	cases := []struct {
+		name string
+		expr string
+		want []string
+	}{
+		{"every minute", "* * * * *", []string{"2024-01-01 00:01", "2024-01-01 00:02"}},
+		{"step from value", "5/20 * * * *", []string{"2024-01-01 00:05", "2024-01-01 00:25", "2024-01-01 00:45", "2024-01-01 01:05"}},
+		{"list and range", "0,30 9-10 * * *", []string{"2024-01-01 09:00", "2024-01-01 09:30", "2024-01-01 10:00", "2024-01-01 10:30", "2024-01-02 09:00"}},
+		{"range step", "0 0 1-10/4 * *", []string{"2024-01-05 00:00", "2024-01-09 00:00", "2024-02-01 00:00"}},
+		{"weekdays", "0 12 * * 1-5", []string{"2024-01-01 12:00", "2024-01-02 12:00", "2024-01-03 12:00", "2024-01-04 12:00", "2024-01-05 12:00", "2024-01-08 12:00"}},
+		{"sunday as 7", "0 0 * * 7", []string{"2024-01-07 00:00", "2024-01-14 00:00"}},
+		{"sunday as 0", "0 0 * * 0", []string{"2024-01-07 00:00", "2024-01-14 00:00"}},
+		{"day of month or day of week", "0 0 13 * 5", []string{"2024-01-05 00:00", "2024-01-12 00:00", "2024-01-13 00:00", "2024-01-19 00:00"}},
+		{"starred step requires both days", "0 0 1 * */7", []string{"2024-09-01 00:00", "2024-12-01 00:00"}},
+		{"leap day", "0 0 29 2 *", []string{"2024-02-29 00:00", "2028-02-29 00:00"}},
+		{"month", "0 0 1 3 *", []string{"2024-03-01 00:00", "2025-03-01 00:00"}},
+	}
+	for _, c := range cases {
+		s, err := ParseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		at := cronStart
+		for _, w := range c.want {
+			want, _ := time.Parse("2006-01-02 15:04", w)
+			at = s.Next(at)
+			if !at.Equal(want) {
+				t.Fatalf("%s: got %v, want %v", c.name, at, want)
+			}
+		}
+	}
+}
+
+func TestCronNextNeverMatches(t *testing.T) {
+	s, err := ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at := s.Next(cronStart); !at.IsZero() {
+		t.Fatal("expected zero time, got", at)
+	}
+}
+
+func TestCronNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+7", 7*60*60)
+	s, err := ParseCron("30 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := s.Next(time.Date(2024, 1, 1, 9, 0, 0, 0, loc))
+	if want := time.Date(2024, 1, 2, 8, 30, 0, 0, loc); !at.Equal(want) || at.Location() != loc {
+		t.Fatal("got", at)
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	exprs := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"5-1 * * * *",
+		"1-x * * * *",
+		"x-1 * * * *",
+		"1,,2 * * * *",
+	}
+	for _, expr := range exprs {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
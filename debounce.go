@@ -0,0 +1,79 @@
+package gobucket
+
+import "time"
+
+//Reducer merges the payload of a debounce task refill into the current payload
+type Reducer func(current, next interface{}) interface{}
+
+//WithMaxWait caps the delay of a debounce task counted from its first fill,
+//so a stream of refills can not postpone the execution forever
+func WithMaxWait(d time.Duration) TaskOption {
+	return func(o *taskOptions) {
+		o.maxWait = d
+	}
+}
+
+//WithReducer merges the payload of a debounce task refill instead of replacing it
+func WithReducer(r Reducer) TaskOption {
+	return func(o *taskOptions) {
+		o.reducer = r
+	}
+}
+
+//debounceAt gets the fire time of a debounce task armed now
+func (t *taskImpl) debounceAt(now time.Time) time.Time {
+	at := now.Add(t.runAfter)
+	if t.maxWait > 0 {
+		if limit := t.enqueued.Add(t.maxWait); limit.Before(at) {
+			return limit
+		}
+	}
+	return at
+}
+
+//debounce replaces or merges the payload and restarts the timer,
+//false when the task is not a debounce task or it has already fired
+func (t *taskImpl) debounce(data interface{}, reducer Reducer) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.taskType != DebounceTask || t.running || t.isQuit() {
+		return false
+	}
+	if t.entry != nil && !t.tb.unschedule(t.entry) {
+		//the timer fired already, the task is about to run
+		return false
+	}
+	if reducer != nil {
+		t.data = reducer(t.data, data)
+	} else {
+		t.data = data
+	}
+	if t.entry != nil {
+		t.armLocked(t.debounceAt(time.Now()), time.Time{})
+	}
+	return true
+}
+
+//debounce refills the debounce task in the bucket or in the pending queue,
+//must be called with lock held
+func (tb *taskBucketImpl) debounce(id string, data interface{}, o *taskOptions) bool {
+	if o.taskType != DebounceTask {
+		return false
+	}
+	t, ok := tb.tasks[id]
+	p, queued := tb.pending.get(id)
+	if queued {
+		t = p.task
+	}
+	if !(ok || queued) || !t.debounce(data, o.reducer) {
+		return false
+	}
+	data = t.payload()
+	if queued {
+		p.data = data
+	}
+	if _, err := tb.persist(id, data, o, queued); err != nil {
+		tb.log("task_bucket: unable to persist debounced task=", id, "err=", err.Error())
+	}
+	return true
+}
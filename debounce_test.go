@@ -0,0 +1,124 @@
+package gobucket
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+//debounceBucket sends the payload of every OnExecute call to the channel
+func debounceBucket(payloads chan interface{}) TaskBucket {
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		payloads <- data
+		return nil
+	}
+	return NewTaskBucket(&BucketConfig{LifeSpan: time.Second, RunAfter: 50 * time.Millisecond, MaxBucket: 10}, e)
+}
+
+func TestDebounceRefillRestartsTimer(t *testing.T) {
+	payloads := make(chan interface{}, 2)
+	tb := debounceBucket(payloads)
+	ctx := context.Background()
+	if err := tb.FillWithOptions(ctx, "a", 1, WithTaskType(DebounceTask)); err != nil {
+		t.Fatal(err)
+	}
+	first, err := tb.Status("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := tb.FillWithOptions(ctx, "a", 2, WithTaskType(DebounceTask)); err != nil {
+		t.Fatal("the refill was rejected", err)
+	}
+	second, err := tb.Status("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.FireAt.Sub(first.FireAt) < 20*time.Millisecond {
+		t.Fatal("the timer was not restarted", first.FireAt, second.FireAt)
+	}
+	select {
+	case data := <-payloads:
+		if data != 2 {
+			t.Fatal("expected the last payload, got", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the debounce task did not run")
+	}
+	select {
+	case data := <-payloads:
+		t.Fatal("the debounce task ran twice", data)
+	case <-time.After(80 * time.Millisecond):
+	}
+}
+
+func TestDebounceReducerMergesPayload(t *testing.T) {
+	payloads := make(chan interface{}, 1)
+	tb := debounceBucket(payloads)
+	sum := WithReducer(func(current, next interface{}) interface{} {
+		return current.(int) + next.(int)
+	})
+	for i := 1; i <= 3; i++ {
+		if err := tb.FillWithOptions(context.Background(), "a", i, WithTaskType(DebounceTask), sum); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case data := <-payloads:
+		if data != 6 {
+			t.Fatal("the payloads were not merged", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the debounce task did not run")
+	}
+}
+
+func TestDebounceMaxWait(t *testing.T) {
+	payloads := make(chan interface{}, 1)
+	tb := debounceBucket(payloads)
+	start := time.Now()
+	for i := 0; ; i++ {
+		//the refills keep coming until the task runs
+		tb.FillWithOptions(context.Background(), "a", i, WithTaskType(DebounceTask), WithMaxWait(100*time.Millisecond))
+		select {
+		case <-payloads:
+		case <-time.After(20 * time.Millisecond):
+			if time.Since(start) > time.Second {
+				t.Fatal("the refills postponed the task past the max wait")
+			}
+			continue
+		}
+		break
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > 300*time.Millisecond {
+		t.Fatal("the task did not run at the max wait", d)
+	}
+}
+
+func TestDebounceRefillAfterFire(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		close(started)
+		<-release
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, RunAfter: 10 * time.Millisecond, MaxBucket: 10}, e)
+	defer close(release)
+	ctx := context.Background()
+	if err := tb.FillWithOptions(ctx, "a", 1, WithTaskType(DebounceTask)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the debounce task did not run")
+	}
+	err := tb.FillWithOptions(ctx, "a", 2, WithTaskType(DebounceTask))
+	if err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Fatal("expected the running task to exist, got", err)
+	}
+}
@@ -0,0 +1,85 @@
+package gobucket
+
+import (
+	"container/list"
+	"errors"
+	"time"
+)
+
+//ErrDuplicateTask is returned by Fill when a task with the id succeeded within the dedupe window
+var ErrDuplicateTask = errors.New("task completed within the dedupe window")
+
+//DedupePolicy decides what Fill does with the id of a task succeeded within the dedupe window
+type DedupePolicy int
+
+const (
+	DedupeReject        DedupePolicy = iota //return ErrDuplicateTask
+	DedupeReturnOutcome                     //accept without running, FillFuture gets the previous result
+)
+
+const defaultDedupeSize = 10000
+
+type dedupeEntry struct {
+	res *TaskResult
+	at  time.Time
+}
+
+//dedupeCache remembers the succeeded task ids, bounded by size (least recently
+//used is evicted first) and by ttl
+type dedupeCache struct {
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func newDedupeCache(ttl time.Duration, size int) *dedupeCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if size <= 0 {
+		size = defaultDedupeSize
+	}
+	return &dedupeCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+//add remembers the task which has succeeded, the failed, exhausted, drained
+//and rescued tasks are not remembered so a redelivery can run them again
+func (c *dedupeCache) add(res *TaskResult) {
+	if c == nil || res.State != StateFinished {
+		return
+	}
+	if el, ok := c.items[res.ID]; ok {
+		c.ll.Remove(el)
+	}
+	c.items[res.ID] = c.ll.PushFront(&dedupeEntry{res: res, at: res.Finished})
+	for c.ll.Len() > c.size {
+		el := c.ll.Back()
+		c.ll.Remove(el)
+		delete(c.items, el.Value.(*dedupeEntry).res.ID)
+	}
+}
+
+//get gets the previous outcome of the task id within the window
+func (c *dedupeCache) get(id string) (*TaskResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+	el, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*dedupeEntry)
+	if time.Since(e.at) > c.ttl {
+		c.ll.Remove(el)
+		delete(c.items, id)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e.res, true
+}
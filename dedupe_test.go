@@ -0,0 +1,110 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDedupeRejectsFinishedTask(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10, DedupeWindow: time.Minute}, e)
+	ctx := context.Background()
+	f, err := tb.FillFuture(ctx, "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := waitResult(f); !ok || res.State != StateFinished {
+		t.Fatal("task did not finish", res)
+	}
+	if err := tb.Fill(ctx, ImmidiateTask, "a", nil); err != ErrDuplicateTask {
+		t.Fatal("expected ErrDuplicateTask, got", err)
+	}
+	if n := len(e.executed()); n != 1 {
+		t.Fatal("the duplicate ran", n)
+	}
+}
+
+func TestDedupeReturnsOutcome(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:     time.Second,
+		MaxBucket:    10,
+		DedupeWindow: time.Minute,
+		DedupePolicy: DedupeReturnOutcome,
+	}, e)
+	ctx := context.Background()
+	f, err := tb.FillFuture(ctx, "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, ok := waitResult(f)
+	if !ok {
+		t.Fatal("task did not end")
+	}
+	f, err = tb.FillFuture(ctx, "a", nil)
+	if err != nil {
+		t.Fatal("the duplicate was rejected", err)
+	}
+	second, ok := waitResult(f)
+	if !ok || second != first {
+		t.Fatal("expected the previous outcome", first, second)
+	}
+	if n := len(e.executed()); n != 1 {
+		t.Fatal("the duplicate ran", n)
+	}
+}
+
+func TestDedupeForgetsFailedTask(t *testing.T) {
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		return errors.New("failed")
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10, DedupeWindow: time.Minute}, e)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		f, err := tb.FillFuture(ctx, "a", nil)
+		if err != nil {
+			t.Fatal("the redelivery of a failed task was rejected", err)
+		}
+		if res, ok := waitResult(f); !ok || res.State != StateFailed {
+			t.Fatal("expected the task to fail", res)
+		}
+	}
+	if n := len(e.executed()); n != 2 {
+		t.Fatal("the redelivery did not run", n)
+	}
+}
+
+func TestDedupeCacheTTL(t *testing.T) {
+	c := newDedupeCache(10*time.Millisecond, 10)
+	c.add(&TaskResult{ID: "a", State: StateFinished, Finished: time.Now()})
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("the id was not remembered")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.get("a"); ok {
+		t.Fatal("the id was remembered after the window")
+	}
+	if c.ll.Len() != 0 || len(c.items) != 0 {
+		t.Fatal("the expired id was kept", c.ll.Len(), len(c.items))
+	}
+}
+
+func TestDedupeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newDedupeCache(time.Minute, 2)
+	now := time.Now()
+	c.add(&TaskResult{ID: "a", State: StateFinished, Finished: now})
+	c.add(&TaskResult{ID: "b", State: StateFinished, Finished: now})
+	c.get("a")
+	c.add(&TaskResult{ID: "c", State: StateFinished, Finished: now})
+	if _, ok := c.get("b"); ok {
+		t.Fatal("the least recently used id was kept")
+	}
+	for _, id := range []string{"a", "c"} {
+		if _, ok := c.get(id); !ok {
+			t.Fatal("the id was evicted", id)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package gobucket
+
+import "errors"
+
+//ErrDependencyFailed is passed to OnExecuteError when a parent task fails
+var ErrDependencyFailed = errors.New("dependency task failed")
+
+//DependencyPolicy decides what happens to the dependents when a parent task
+//fails, times out or is drained
+type DependencyPolicy int
+
+const (
+	CancelDependents DependencyPolicy = iota //call OnExecuteError with ErrDependencyFailed, then drain
+	SkipDependents                           //drain silently without calling the executor
+)
+
+//dependency points to a parent task, nil bucket means the same bucket
+type dependency struct {
+	tb TaskBucket
+	id string
+}
+
+//watchParents registers the task to its parents when it is filled, so a parent
+//ending while the task waits in the pending queue is not missed. The task is
+//launched by start once every parent finished successfully
+func (t *taskImpl) watchParents() {
+	if len(t.deps) == 0 {
+		return
+	}
+	t.mux.Lock()
+	//one extra count guards the launch until the task is started
+	t.waiting = len(t.deps) + 1
+	t.state = StateWaiting
+	t.mux.Unlock()
+	for _, d := range t.deps {
+		tb := d.tb
+		if tb == nil {
+			tb = t.tb
+		}
+		if !tb.watch(d.id, t.parentDone) {
+			//parent is no longer in the bucket, only its retained result tells
+			//whether it finished, an unknown parent counts as failed
+			res, ok := tb.Result(d.id)
+			t.parentDone(ok && res.State == StateFinished)
+		}
+	}
+}
+
+//parentDone is called when a parent task ends
+func (t *taskImpl) parentDone(ok bool) {
+	t.mux.Lock()
+	if t.isQuit() || t.waiting == 0 {
+		t.mux.Unlock()
+		return
+	}
+	if !ok {
+		t.waiting = 0
+		t.state = StateFailed
+		t.onExecuteErr = ErrDependencyFailed
+		t.mux.Unlock()
+		t.log(t.id, "parent task failed, policy=", t.depPolicy)
+		if t.depPolicy == CancelDependents {
+			t.taskErr = t.e.OnExecuteError(t.ctx, t.id, t.data, ErrDependencyFailed)
+		}
+		t.drain(t.ctx, false)
+		return
+	}
+	t.waiting--
+	ready := t.waiting == 0
+	t.mux.Unlock()
+	if ready {
+		t.log(t.id, "all parent tasks finished")
+		t.launch()
+	}
+}
+
+//parents gets the parent tasks
+func (t *taskImpl) parents() []dependency {
+	return t.deps
+}
+
+//queuedParent checks whether a parent in this bucket is still in the pending
+//queue, must be called with lock held
+func (tb *taskBucketImpl) queuedParent(t task) bool {
+	for _, d := range t.parents() {
+		if d.tb != nil && d.tb != TaskBucket(tb) {
+			continue
+		}
+		if _, queued := tb.pending.get(d.id); queued {
+			return true
+		}
+	}
+	return false
+}
+
+//watch registers fn to be called when the task ends,
+//false when the task is not in the bucket
+func (tb *taskBucketImpl) watch(id string, fn func(ok bool)) bool {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	if _, ok := tb.tasks[id]; !ok {
+		if _, ok := tb.pending.get(id); !ok {
+			return false
+		}
+	}
+	tb.watchers[id] = append(tb.watchers[id], fn)
+	return true
+}
+
+//unwatch takes the watchers of the task, must be called with lock held
+func (tb *taskBucketImpl) unwatch(id string) []func(ok bool) {
+	fns := tb.watchers[id]
+	delete(tb.watchers, id)
+	return fns
+}
+
+//notifyWatchers tells the dependents how the parent ended
+func notifyWatchers(fns []func(ok bool), ok bool) {
+	for _, fn := range fns {
+		go fn(ok)
+	}
+}
@@ -0,0 +1,131 @@
+package gobucket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDependentWaitsForParent(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(50 * time.Millisecond)}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10}, e)
+	ctx := context.Background()
+	if err := tb.FillWithOptions(ctx, "parent", nil); err != nil {
+		t.Fatal(err)
+	}
+	f, err := tb.FillFuture(ctx, "child", nil, WithDependsOn("parent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := waitResult(f)
+	if !ok || res.State != StateFinished {
+		t.Fatal("child did not finish", res)
+	}
+	if runs := e.executed(); len(runs) != 2 || runs[0] != "parent" {
+		t.Fatal("unexpected runs", runs)
+	}
+}
+
+func TestDependentOfUnknownParentFails(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10}, e)
+	f, err := tb.FillFuture(context.Background(), "child", nil, WithDependsOn("nonexistent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := waitResult(f)
+	if !ok {
+		t.Fatal("child did not end")
+	}
+	if res.Err != ErrDependencyFailed || len(e.executed()) != 0 {
+		t.Fatal("child ran without its parent", res.Err, e.executed())
+	}
+}
+
+func TestDependentOfRetainedParent(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10, ResultRetention: time.Minute}, e)
+	ctx := context.Background()
+	parent, err := tb.FillFuture(ctx, "parent", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := waitResult(parent); !ok {
+		t.Fatal("parent did not finish")
+	}
+	f, err := tb.FillFuture(ctx, "child", nil, WithDependsOn("parent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := waitResult(f); !ok || res.State != StateFinished {
+		t.Fatal("child did not finish", res)
+	}
+}
+
+func TestDependentIsNotPromotedBeforeQueuedParent(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(20 * time.Millisecond)}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:   time.Second,
+		MaxBucket:  1,
+		MaxPending: 10,
+		QueueOrder: PriorityOrder,
+	}, e)
+	ctx := context.Background()
+	if err := tb.FillWithOptions(ctx, "x", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.FillWithOptions(ctx, "p", nil); err != nil {
+		t.Fatal(err)
+	}
+	f, err := tb.FillFuture(ctx, "d", nil, WithDependsOn("p"), WithPriority(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := waitResult(f); !ok || res.State != StateFinished {
+		t.Fatal("the dependent did not finish", e.executed())
+	}
+	if runs := e.executed(); len(runs) != 3 || runs[1] != "p" || runs[2] != "d" {
+		t.Fatal("unexpected runs", runs)
+	}
+}
+
+func TestQueuedDependentFailsWithItsParent(t *testing.T) {
+	failed := make(chan error, 1)
+	e := &funcExecutor{execute: sleeping(200 * time.Millisecond)}
+	e.failed = func(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+		failed <- onExecuteErr
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 1, MaxPending: 1}, e)
+	parents := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 1}, &funcExecutor{
+		execute: func(ctx context.Context, id string, data interface{}) error {
+			time.Sleep(20 * time.Millisecond)
+			return errFull
+		},
+	})
+	ctx := context.Background()
+	tb.Fill(ctx, ImmidiateTask, "x", nil)
+	parents.Fill(ctx, ImmidiateTask, "p", nil)
+	f, err := tb.FillFuture(ctx, "d", nil, WithDependsOnBucket(parents, "p"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.Stats().Pending != 1 {
+		t.Fatal("the dependent is not queued")
+	}
+	select {
+	case err := <-failed:
+		if err != ErrDependencyFailed {
+			t.Fatal("unexpected error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the dependent was not cancelled")
+	}
+	res, ok := waitResult(f)
+	if !ok || res.State != StateFailed {
+		t.Fatal("unexpected result", res)
+	}
+	if st := tb.Stats(); st.Pending != 0 || len(e.executed()) != 1 {
+		t.Fatal("the dependent is still queued", st, e.executed())
+	}
+}
@@ -0,0 +1,152 @@
+package gobucket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//EventType defines the lifecycle event of a task
+type EventType string
+
+const (
+	EventFilled       EventType = "filled"        //task accepted by Fill, or queued as pending
+	EventStarted      EventType = "started"       //OnExecute attempt started
+	EventFinished     EventType = "finished"      //task ended successfully
+	EventFailed       EventType = "failed"        //task ended with OnExecute error
+	EventExhausted    EventType = "exhausted"     //life span or queue timeout reached
+	EventDrained      EventType = "drained"       //removed by Drain
+	EventRescued      EventType = "rescued"       //removed by Rescue
+	EventRejectedFull EventType = "rejected_full" //Fill rejected, task buffer exceeded
+)
+
+//DropPolicy decides which event is dropped when the subscriber buffer is full
+type DropPolicy int
+
+const (
+	DropNewest DropPolicy = iota //drop the event being published
+	DropOldest                   //drop the oldest buffered event
+)
+
+const defaultEventBuffer = 256
+
+//Event is a lifecycle event of a task
+type Event struct {
+	Type     EventType
+	ID       string
+	Time     time.Time     //when the event happened
+	Enqueued time.Time     //when the task was filled
+	Started  time.Time     //when the last run started, zero when never started
+	Wait     time.Duration //from filled to started
+	Duration time.Duration //from started to the event
+	Attempt  int
+	Err      error
+}
+
+//eventHub fans the events out to the subscribers
+type eventHub struct {
+	mux  sync.Mutex
+	subs map[chan Event]struct{}
+	size int
+	drop DropPolicy
+}
+
+func newEventHub(size int, drop DropPolicy) *eventHub {
+	if size <= 0 {
+		size = defaultEventBuffer
+	}
+	return &eventHub{
+		subs: make(map[chan Event]struct{}),
+		size: size,
+		drop: drop,
+	}
+}
+
+//Subscribe streams the lifecycle events of the bucket until the context is done,
+//the channel is closed afterwards. A slow subscriber loses events according to
+//BucketConfig.EventDrop instead of blocking the bucket
+//args:
+//	ctx: context passed, cancel it to unsubscribe
+//returns:
+//	event stream
+func (tb *taskBucketImpl) Subscribe(ctx context.Context) <-chan Event {
+	h := tb.events
+	ch := make(chan Event, h.size)
+	h.mux.Lock()
+	h.subs[ch] = struct{}{}
+	h.mux.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.mux.Lock()
+		delete(h.subs, ch)
+		close(ch)
+		h.mux.Unlock()
+	}()
+	return ch
+}
+
+//publish sends the event to every subscriber without blocking
+func (tb *taskBucketImpl) publish(ev Event) {
+	h := tb.events
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for ch := range h.subs {
+		h.send(ch, ev)
+	}
+}
+
+func (h *eventHub) send(ch chan Event, ev Event) {
+	for {
+		select {
+		case ch <- ev:
+			return
+		default:
+		}
+		if h.drop == DropNewest {
+			return
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+//taskEvent builds the event of a task
+func taskEvent(et EventType, id string, enqueued, started time.Time, attempt int, err error) Event {
+	ev := Event{
+		Type:     et,
+		ID:       id,
+		Time:     time.Now(),
+		Enqueued: enqueued,
+		Started:  started,
+		Attempt:  attempt,
+		Err:      err,
+	}
+	if !started.IsZero() {
+		ev.Wait = started.Sub(enqueued)
+		ev.Duration = ev.Time.Sub(started)
+	}
+	return ev
+}
+
+//resultEvent builds the event of an ended task
+func resultEvent(res *TaskResult) Event {
+	et := EventFinished
+	switch res.State {
+	case StateFailed:
+		et = EventFailed
+	case StateExhausted:
+		et = EventExhausted
+	case StateDrained:
+		et = EventDrained
+	case StateRescued:
+		et = EventRescued
+	}
+	ev := taskEvent(et, res.ID, res.Enqueued, res.Started, res.Attempts, res.Err)
+	ev.Time = res.Finished
+	if !res.Started.IsZero() {
+		ev.Duration = res.Finished.Sub(res.Started)
+	}
+	return ev
+}
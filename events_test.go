@@ -0,0 +1,104 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//collect reads the events by task id until the end event of the task
+func collect(t *testing.T, events <-chan Event, id string) map[string][]Event {
+	evs := make(map[string][]Event)
+	for {
+		select {
+		case ev := <-events:
+			evs[ev.ID] = append(evs[ev.ID], ev)
+			if ev.ID != id {
+				continue
+			}
+			switch ev.Type {
+			case EventFinished, EventFailed, EventExhausted, EventDrained, EventRescued:
+				return evs
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the task did not end, events", evs)
+		}
+	}
+}
+
+func TestEventSequence(t *testing.T) {
+	var calls int32
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("try again")
+		}
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Second,
+		MaxBucket: 1,
+		Retry:     &RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond},
+	}, e)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := tb.Subscribe(ctx)
+	if err := tb.Fill(ctx, ImmidiateTask, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.Fill(ctx, ImmidiateTask, "b", nil); err != errFull {
+		t.Fatal("expected the bucket to be full, got", err)
+	}
+	all := collect(t, events, "a")
+	if evs := all["b"]; len(evs) != 1 || evs[0].Type != EventRejectedFull || evs[0].Err != errFull {
+		t.Fatal("expected a rejected event", evs)
+	}
+	evs := all["a"]
+	var types []EventType
+	var attempts []int
+	for _, ev := range evs {
+		types = append(types, ev.Type)
+		attempts = append(attempts, ev.Attempt)
+	}
+	if want := []EventType{EventFilled, EventStarted, EventStarted, EventFinished}; !reflect.DeepEqual(types, want) {
+		t.Fatal("unexpected event sequence", types)
+	}
+	if want := []int{0, 1, 2, 2}; !reflect.DeepEqual(attempts, want) {
+		t.Fatal("unexpected attempts", attempts)
+	}
+	if end := evs[len(evs)-1]; end.Started.IsZero() || end.Duration < 10*time.Millisecond {
+		t.Fatal("the end event has no run duration", end)
+	}
+}
+
+//dropped publishes three events to a subscriber with a buffer of two
+func dropped(t *testing.T, drop DropPolicy) []string {
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 1, EventBuffer: 2, EventDrop: drop}, &funcExecutor{})
+	ctx, cancel := context.WithCancel(context.Background())
+	events := tb.Subscribe(ctx)
+	for _, id := range []string{"a", "b", "c"} {
+		tb.publish(taskEvent(EventFilled, id, time.Now(), time.Time{}, 0, nil))
+	}
+	cancel()
+	var ids []string
+	for ev := range events {
+		ids = append(ids, ev.ID)
+	}
+	return ids
+}
+
+func TestEventDropNewest(t *testing.T) {
+	if ids := dropped(t, DropNewest); !reflect.DeepEqual(ids, []string{"a", "b"}) {
+		t.Fatal("expected the newest event to be dropped", ids)
+	}
+}
+
+func TestEventDropOldest(t *testing.T) {
+	if ids := dropped(t, DropOldest); !reflect.DeepEqual(ids, []string{"b", "c"}) {
+		t.Fatal("expected the oldest event to be dropped", ids)
+	}
+}
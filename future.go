@@ -0,0 +1,136 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	//ErrTaskDrained is the result error of a drained task
+	ErrTaskDrained = errors.New("task drained")
+	//ErrTaskRescued is the result error of a rescued task
+	ErrTaskRescued = errors.New("task rescued")
+)
+
+//TaskResult is the final outcome of a task
+type TaskResult struct {
+	ID       string
+	State    TaskState
+	Result   interface{} //value returned by ResultExecutor.OnExecuteResult
+	Err      error
+	Enqueued time.Time
+	Started  time.Time //start of the last run, zero when never started
+	Finished time.Time
+	Attempts int
+}
+
+//Future is the handle of a task filled with FillFuture
+type Future struct {
+	id   string
+	done chan struct{}
+	once sync.Once
+	res  *TaskResult
+}
+
+func newFuture(id string) *Future {
+	return &Future{
+		id:   id,
+		done: make(chan struct{}),
+	}
+}
+
+//ID gets the task identity
+func (f *Future) ID() string {
+	return f.id
+}
+
+//Done is closed once the task ended
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+//Wait blocks until the task ended or the context is done
+//returns:
+//	the final result and the task error
+func (f *Future) Wait(ctx context.Context) (*TaskResult, error) {
+	select {
+	case <-f.done:
+		return f.res, f.res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *Future) complete(res *TaskResult) {
+	f.once.Do(func() {
+		f.res = res
+		close(f.done)
+	})
+}
+
+//FillFuture works like FillWithOptions, and returns the handle to wait for the task
+//args:
+//	ctx: context passed
+//	id: identity of the task
+//	data: task payload
+//	opts: per task options
+//returns:
+//	task handle and fill operation error
+func (tb *taskBucketImpl) FillFuture(ctx context.Context, id string, data interface{}, opts ...TaskOption) (*Future, error) {
+	f := newFuture(id)
+	if err := tb.fill(ctx, id, data, f, opts); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+//Result gets the outcome of a finished task, kept for BucketConfig.ResultRetention
+func (tb *taskBucketImpl) Result(id string) (*TaskResult, bool) {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	res, ok := tb.results[id]
+	return res, ok
+}
+
+//settle records how the task ended, must be called with lock held,
+//the returned func notifies the waiters and should be called after unlock
+func (tb *taskBucketImpl) settle(t task, res *TaskResult) func() {
+	//a task leaving the pending queue was never started, quit it so the
+	//parents it still watches are ignored
+	t.quit()
+	fns := tb.unwatch(res.ID)
+	tb.leave(t)
+	if !tb.keepStored || res.State != StateDrained {
+		tb.unpersist(res.ID)
+	}
+	fs := tb.futures[res.ID]
+	delete(tb.futures, res.ID)
+	tb.retain(res)
+	tb.dedupe.add(res)
+	return func() {
+		tb.flush(nil)
+		tb.publish(resultEvent(res))
+		notifyWatchers(fns, res.State == StateFinished)
+		for _, f := range fs {
+			f.complete(res)
+		}
+	}
+}
+
+//retain keeps the result until the retention window passed
+func (tb *taskBucketImpl) retain(res *TaskResult) {
+	retention := tb.conf().ResultRetention
+	if retention <= 0 {
+		return
+	}
+	tb.results[res.ID] = res
+	tb.sched.schedule(res.Finished.Add(retention), func() {
+		tb.mux.Lock()
+		if tb.results[res.ID] == res {
+			delete(tb.results, res.ID)
+		}
+		tb.mux.Unlock()
+	})
+}
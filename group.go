@@ -37,6 +37,7 @@ type TaskBucketGroup interface {
 	StopWork()
 	SetOnPeerScheduleFailed(f OnPeerScheduleFailed)
 	Fill(ctx context.Context, task, pid string, data interface{}) error
+	Pipeline(stages ...string) (*Pipeline, error)
 }
 
 type bucketGroup struct {
@@ -0,0 +1,86 @@
+package gobucket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//funcExecutor calls the hook when set, nil hooks do nothing
+type funcExecutor struct {
+	execute   func(ctx context.Context, id string, data interface{}) error
+	exhausted func(ctx context.Context, id string, data interface{}) error
+	finish    func(ctx context.Context, id string, data interface{}) error
+	panicked  func(ctx context.Context, id string, data interface{}) error
+	failed    func(ctx context.Context, id string, data interface{}, onExecuteErr error) error
+
+	mux  sync.Mutex
+	runs []string
+}
+
+func (e *funcExecutor) OnExecute(ctx context.Context, id string, data interface{}) error {
+	e.mux.Lock()
+	e.runs = append(e.runs, id)
+	e.mux.Unlock()
+	if e.execute == nil {
+		return nil
+	}
+	return e.execute(ctx, id, data)
+}
+
+func (e *funcExecutor) OnFinish(ctx context.Context, id string, data interface{}) error {
+	if e.finish == nil {
+		return nil
+	}
+	return e.finish(ctx, id, data)
+}
+
+func (e *funcExecutor) OnTaskExhausted(ctx context.Context, id string, data interface{}) error {
+	if e.exhausted == nil {
+		return nil
+	}
+	return e.exhausted(ctx, id, data)
+}
+
+func (e *funcExecutor) OnExecuteError(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+	if e.failed == nil {
+		return nil
+	}
+	return e.failed(ctx, id, data, onExecuteErr)
+}
+
+func (e *funcExecutor) OnPanic(ctx context.Context, id string, data interface{}) error {
+	if e.panicked == nil {
+		return nil
+	}
+	return e.panicked(ctx, id, data)
+}
+
+//executed gets the ids passed to OnExecute in call order
+func (e *funcExecutor) executed() []string {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return append([]string(nil), e.runs...)
+}
+
+//sleeping returns an OnExecute hook taking d or until the context is done
+func sleeping(d time.Duration) func(ctx context.Context, id string, data interface{}) error {
+	return func(ctx context.Context, id string, data interface{}) error {
+		select {
+		case <-time.After(d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//waitResult waits for the future with a one second limit
+func waitResult(f *Future) (*TaskResult, bool) {
+	select {
+	case <-f.Done():
+		return f.res, true
+	case <-time.After(time.Second):
+		return nil, false
+	}
+}
@@ -2,22 +2,56 @@ package gobucket
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const efull = "task buffer exceeded"
 
+var errFull = errors.New(efull)
+
 //TaskBucket works as a bucket implementation for tasks pool
 type TaskBucket interface {
 	Fill(ctx context.Context, taskType TaskType, id string, data interface{}) error
+	FillWithOptions(ctx context.Context, id string, data interface{}, opts ...TaskOption) error
+	FillWait(ctx context.Context, id string, data interface{}, opts ...TaskOption) error
+	FillFuture(ctx context.Context, id string, data interface{}, opts ...TaskOption) (*Future, error)
+	Result(id string) (*TaskResult, bool)
+	Status(id string) (*TaskStatus, error)
+	List(filter *TaskFilter) []*TaskStatus
+	Stats() BucketStats
+	Subscribe(ctx context.Context) <-chan Event
+	Shutdown(ctx context.Context, handoff HandoffFunc) error
+	WaitIdle(ctx context.Context) error
+	SetRateLimit(perSecond float64, burst int)
+	Pause()
+	Resume()
+	Paused() bool
+	Reconfigure(cfg *BucketConfig) error
+	Snapshot(w io.Writer) error
+	Restore(r io.Reader) error
 	Drain(ctx context.Context, id string) error
-	Rescue(ctx context.Context) error
+	DrainWhere(ctx context.Context, sel Selector) ([]string, error)
+	DrainPrefix(ctx context.Context, prefix string) ([]string, error)
+	Rescue(ctx context.Context) ([]*RescuedTask, error)
 	remove(id string) error
 	length() int
-	panic(panic bool)
+	acquire(ctx context.Context, quit <-chan bool) (time.Duration, error)
+	release()
+	schedule(at time.Time, fn func()) *timerEntry
+	unschedule(e *timerEntry) bool
+	watch(id string, fn func(ok bool)) bool
+	decode(raw []byte) (interface{}, error)
+	waitResume(ctx context.Context, quit <-chan bool) (time.Duration, error)
+	conf() *BucketConfig
+	isClosed() bool
+	publish(ev Event)
 }
 
 //Executor defines a pclient task definition
@@ -29,13 +63,38 @@ type Executor interface {
 	OnPanic(ctx context.Context, id string, data interface{}) error                            //perform something when panic happens
 }
 
+//ResultExecutor is an Executor which also produces a result value,
+//the bucket calls OnExecuteResult instead of OnExecute
+type ResultExecutor interface {
+	Executor
+	OnExecuteResult(ctx context.Context, id string, data interface{}) (interface{}, error)
+}
+
 //taskBucketImpl task bucket object holder and methods
 type taskBucketImpl struct {
-	mux       sync.Mutex
-	tasks     map[string]task
-	config    *BucketConfig
-	executor  Executor
-	panicChan chan bool
+	mux      sync.Mutex
+	tasks    map[string]task
+	config   atomic.Value //*BucketConfig, replaced by Reconfigure
+	executor Executor
+	pending  *pendingQueue
+	freed    chan struct{}
+	workers  chan struct{}
+	sched    *scheduler
+	watchers map[string][]func(ok bool)
+	decoder  func(raw []byte) (interface{}, error)
+	futures  map[string][]*Future
+	results  map[string]*TaskResult
+	events   *eventHub
+	closed   bool
+	dedupe   *dedupeCache
+	limiter  *rateLimiter
+	paused   chan struct{} //closed on Resume, nil when not paused
+
+	partitions map[string][]task //tasks sharing a partition key in fill order
+
+	writes     []*storeOp //store writes not applied yet
+	flushMux   sync.Mutex //applies the store writes one batch at a time
+	keepStored bool       //the tasks drained by Shutdown stay in the store
 }
 
 //NewTaskBucket creates new task bucket
@@ -45,12 +104,34 @@ type taskBucketImpl struct {
 //returns:
 //	task bucket
 func NewTaskBucket(cfg *BucketConfig, executor Executor) TaskBucket {
-	return &taskBucketImpl{
-		tasks:     make(map[string]task, cfg.MaxBucket),
-		config:    cfg,
-		executor:  executor,
-		panicChan: make(chan bool, cfg.MaxBucket),
+	tb := newTaskBucket(cfg, executor)
+	tb.restore()
+	return tb
+}
+
+func newTaskBucket(cfg *BucketConfig, executor Executor) *taskBucketImpl {
+	var workers chan struct{}
+	if cfg.MaxConcurrent > 0 {
+		workers = make(chan struct{}, cfg.MaxConcurrent)
 	}
+	tb := &taskBucketImpl{
+		tasks:      make(map[string]task, cfg.MaxBucket),
+		executor:   executor,
+		pending:    newPendingQueue(cfg.QueueOrder),
+		freed:      make(chan struct{}),
+		workers:    workers,
+		sched:      newScheduler(cfg.Dispatchers),
+		watchers:   make(map[string][]func(ok bool)),
+		partitions: make(map[string][]task),
+		futures:    make(map[string][]*Future),
+		results:    make(map[string]*TaskResult),
+		events:     newEventHub(cfg.EventBuffer, cfg.EventDrop),
+		dedupe:     newDedupeCache(cfg.DedupeWindow, cfg.DedupeSize),
+		limiter:    newRateLimiter(cfg.RateLimit, cfg.RateBurst),
+	}
+	c := *cfg
+	tb.config.Store(&c)
+	return tb
 }
 
 //Fill puts the task to task buffer, run the job right away
@@ -61,30 +142,206 @@ func NewTaskBucket(cfg *BucketConfig, executor Executor) TaskBucket {
 //returns:
 //	fill operation error
 func (tb *taskBucketImpl) Fill(ctx context.Context, tt TaskType, id string, data interface{}) error {
+	return tb.FillWithOptions(ctx, id, data, WithTaskType(tt))
+}
+
+//FillWithOptions puts the task to task buffer, the options override the bucket config
+//args:
+//	ctx: context passed
+//	id: identity of the task
+//	data: task payload
+//	opts: per task options
+//returns:
+//	fill operation error
+func (tb *taskBucketImpl) FillWithOptions(ctx context.Context, id string, data interface{}, opts ...TaskOption) error {
+	return tb.fill(ctx, id, data, nil, opts)
+}
+
+//fill puts the task to task buffer, the future is registered when given
+func (tb *taskBucketImpl) fill(ctx context.Context, id string, data interface{}, f *Future, opts []TaskOption) error {
+	cfg := tb.conf()
+	o, err := newTaskOptions(cfg, opts)
+	if err != nil {
+		return err
+	}
+	for _, d := range o.deps {
+		if d.id == id && (d.tb == nil || d.tb == TaskBucket(tb)) {
+			return fmt.Errorf("task with id=%s can not depend on itself", id)
+		}
+	}
+	task := newTask(ctx, id, data, o, cfg.Verbose, tb, tb.executor)
 	tb.mux.Lock()
+	if tb.closed {
+		tb.mux.Unlock()
+		return ErrBucketClosed
+	}
 	_, ok := tb.tasks[id]
-	tb.mux.Unlock()
+	if !ok {
+		_, ok = tb.pending.get(id)
+	}
+	if ok && tb.debounce(id, data, o) {
+		if f != nil {
+			tb.futures[id] = append(tb.futures[id], f)
+		}
+		tb.mux.Unlock()
+		tb.flush(nil)
+		tb.log("task_bucket: task=", id, "debounced")
+		tb.publish(taskEvent(EventFilled, id, time.Now(), time.Time{}, 0, nil))
+		return nil
+	}
 	if ok {
+		tb.mux.Unlock()
 		return fmt.Errorf("task with id=%s exists", id)
 	}
-	var isFull bool
-	tb.mux.Lock()
-	isFull = len(tb.tasks) > tb.config.MaxBucket-1
-	tb.mux.Unlock()
-	if isFull {
-		tb.log("task_bucket: unable to fill bucket for task=", id, "max=", tb.config.MaxBucket)
-		return errors.New(efull)
+	if res, ok := tb.dedupe.get(id); ok {
+		tb.mux.Unlock()
+		tb.log("task_bucket: task=", id, "succeeded within the dedupe window")
+		if cfg.DedupePolicy == DedupeReject {
+			return ErrDuplicateTask
+		}
+		if f != nil {
+			f.complete(res)
+		}
+		return nil
+	}
+	if len(tb.tasks) > cfg.MaxBucket-1 {
+		if tb.pending.Len() > cfg.MaxPending-1 {
+			tb.mux.Unlock()
+			tb.log("task_bucket: unable to fill bucket for task=", id, "max=", cfg.MaxBucket)
+			tb.publish(taskEvent(EventRejectedFull, id, time.Now(), time.Time{}, 0, errFull))
+			return errFull
+		}
+		op, err := tb.persist(id, data, o, true)
+		if err != nil {
+			tb.mux.Unlock()
+			return err
+		}
+		tb.enqueue(ctx, task, id, data, o.priority)
+		tb.join(task)
+		if f != nil {
+			tb.futures[id] = append(tb.futures[id], f)
+		}
+		waiting := tb.pending.Len()
+		tb.mux.Unlock()
+		if err := tb.stored(id, op); err != nil {
+			return err
+		}
+		task.watchParents()
+		tb.log("task_bucket: bucket is full, task=", id, "is waiting, pending=", waiting)
+		tb.publish(taskEvent(EventFilled, id, time.Now(), time.Time{}, 0, nil))
+		return nil
+	}
+	op, err := tb.persist(id, data, o, false)
+	if err != nil {
+		tb.mux.Unlock()
+		return err
 	}
-	//prepare the tax
-	task := newTask(tt, id, tb.config, data, tb)
-	tb.mux.Lock()
 	tb.tasks[id] = task
+	tb.join(task)
+	if f != nil {
+		tb.futures[id] = append(tb.futures[id], f)
+	}
 	tb.mux.Unlock()
-	//run the task: go routine
-	go task.run(ctx, tb.executor)
+	if err := tb.stored(id, op); err != nil {
+		return err
+	}
+	task.watchParents()
+	tb.publish(taskEvent(EventFilled, id, time.Now(), time.Time{}, 0, nil))
+	//run the task right away, or arm the scheduler
+	task.start()
 	return nil
 }
 
+//FillWait works like FillWithOptions, but blocks until the bucket has room
+//for the task instead of returning task buffer exceeded
+//args:
+//	ctx: context passed, cancel it to stop waiting
+//	id: identity of the task
+//	data: task payload
+//	opts: per task options
+//returns:
+//	fill operation error
+func (tb *taskBucketImpl) FillWait(ctx context.Context, id string, data interface{}, opts ...TaskOption) error {
+	for {
+		tb.mux.Lock()
+		freed := tb.freed
+		tb.mux.Unlock()
+		err := tb.FillWithOptions(ctx, id, data, opts...)
+		if err != errFull {
+			return err
+		}
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//enqueue puts the task to the pending queue, must be called with lock held
+func (tb *taskBucketImpl) enqueue(ctx context.Context, t task, id string, data interface{}, priority int) {
+	p := &pendingTask{
+		ctx:      ctx,
+		task:     t,
+		id:       id,
+		data:     data,
+		priority: priority,
+	}
+	if timeout := tb.conf().QueueTimeout; timeout > 0 {
+		p.timer = time.AfterFunc(timeout, func() {
+			tb.expire(id)
+		})
+	}
+	tb.pending.push(p)
+}
+
+//expire drops the task which waits longer than the queue timeout
+func (tb *taskBucketImpl) expire(id string) {
+	tb.mux.Lock()
+	p := tb.pending.remove(id)
+	var settled func()
+	if p != nil {
+		settled = tb.settle(p.task, pendingResult(p, StateExhausted, errors.New("queue timeout exceeded")))
+		tb.notify()
+	}
+	tb.mux.Unlock()
+	if p == nil {
+		return
+	}
+	tb.log("task_bucket: task=", id, "exceeded queue timeout")
+	tb.executor.OnTaskExhausted(p.ctx, p.id, p.data)
+	settled()
+}
+
+//pendingResult gets the result of a task leaving the pending queue
+func pendingResult(p *pendingTask, state TaskState, err error) *TaskResult {
+	res := p.task.outcome()
+	res.State, res.Err = state, err
+	return res
+}
+
+//promote moves waiting tasks to the bucket while there is free slot,
+//must be called with lock held, returns the tasks to be run
+func (tb *taskBucketImpl) promote() []*pendingTask {
+	var ps []*pendingTask
+	limit := tb.conf().MaxBucket
+	for len(tb.tasks) < limit {
+		p := tb.pending.popWhere(tb.ready)
+		if p == nil {
+			break
+		}
+		tb.tasks[p.id] = p.task
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+//notify wakes up FillWait callers, must be called with lock held
+func (tb *taskBucketImpl) notify() {
+	close(tb.freed)
+	tb.freed = make(chan struct{})
+}
+
 //Drain removes the task from the task bucket
 //args:
 //	ctx: passed ctx
@@ -94,6 +351,17 @@ func (tb *taskBucketImpl) Fill(ctx context.Context, tt TaskType, id string, data
 func (tb *taskBucketImpl) Drain(ctx context.Context, id string) error {
 	tb.mux.Lock()
 	task, ok := tb.tasks[id]
+	var p *pendingTask
+	if !ok {
+		p = tb.pending.remove(id)
+	}
+	if p != nil {
+		settled := tb.settle(p.task, pendingResult(p, StateDrained, ErrTaskDrained))
+		tb.notify()
+		tb.mux.Unlock()
+		settled()
+		return nil
+	}
 	tb.mux.Unlock()
 	if ok {
 		return task.drain(ctx, true)
@@ -101,60 +369,145 @@ func (tb *taskBucketImpl) Drain(ctx context.Context, id string) error {
 	return fmt.Errorf("task with id %s is not found", id)
 }
 
-func (tb *taskBucketImpl) Rescue(ctx context.Context) error {
+//Rescue stops every task in the bucket and runs the executor.OnPanic,
+//the running tasks get the panic signal
+//args:
+//	ctx: passed ctx, its deadline bounds the wait for the running tasks
+//return:
+//	the rescued tasks with their progress, context error when some running
+//	tasks are not rescued in time
+func (tb *taskBucketImpl) Rescue(ctx context.Context) ([]*RescuedTask, error) {
 	tb.mux.Lock()
-	isNill := (tb.tasks == nil)
-	tb.mux.Unlock()
-	if isNill {
-		return errors.New("task already nil")
+	if tb.tasks == nil {
+		tb.mux.Unlock()
+		return nil, errors.New("task already nil")
 	}
-	tb.mux.Lock()
-	ln := len(tb.tasks)
-	for _, t := range tb.tasks {
-		go t.rescue(ctx)
+	var rescued []*RescuedTask
+	var waiting []*pendingTask
+	var settled []func()
+	for p := tb.pending.pop(); p != nil; p = tb.pending.pop() {
+		waiting = append(waiting, p)
+		rescued = append(rescued, &RescuedTask{TaskStatus: *pendingStatus(p), Data: p.data})
+		settled = append(settled, tb.settle(p.task, pendingResult(p, StateRescued, ErrTaskRescued)))
 	}
-	for i := 0; i < ln; i++ {
-		<-tb.panicChan
+	ts := make([]task, 0, len(tb.tasks))
+	for _, t := range tb.tasks {
+		ts = append(ts, t)
 	}
 	tb.mux.Unlock()
-	return nil
+	for _, p := range waiting {
+		tb.executor.OnPanic(ctx, p.id, p.data)
+	}
+	for _, fn := range settled {
+		fn()
+	}
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for _, t := range ts {
+		wg.Add(1)
+		go func(t task) {
+			defer wg.Done()
+			st := t.status()
+			if !t.rescue(ctx) {
+				return
+			}
+			mux.Lock()
+			rescued = append(rescued, &RescuedTask{TaskStatus: *st, Data: t.payload()})
+			mux.Unlock()
+		}(t)
+	}
+	wg.Wait()
+	tb.log("task_bucket: rescued", len(rescued), "task(s)")
+	return rescued, ctx.Err()
 }
 
-//remove removes the task from internal task bucket
+//remove removes the task from internal task bucket,
+//the freed slot is given to the next waiting task
 func (tb *taskBucketImpl) remove(id string) error {
 	tb.mux.Lock()
-	isNill := (tb.tasks == nil)
-	tb.mux.Unlock()
-	if isNill {
+	if tb.tasks == nil {
+		tb.mux.Unlock()
 		return errors.New("task already nil")
 	}
-	tb.mux.Lock()
-	_, ok := tb.tasks[id]
-	tb.mux.Unlock()
-
+	t, ok := tb.tasks[id]
 	if ok {
-		tb.mux.Lock()
 		delete(tb.tasks, id)
+	} else if p := tb.pending.remove(id); p != nil {
+		//a waiting task ends before its promotion when its parent failed
+		t = p.task
+	} else {
 		tb.mux.Unlock()
-		return nil
+		return fmt.Errorf("task with id %s is not exists, unable to remove", id)
 	}
-	return fmt.Errorf("task with id %s is not exists, unable to remove", id)
+	settled := tb.settle(t, t.outcome())
+	ps := tb.promote()
+	tb.notify()
+	tb.mux.Unlock()
+	settled()
+	for _, p := range ps {
+		p.task.start()
+	}
+	return nil
 }
 
-//length gets the actual length of the map
+//length gets the actual length of the map, including the waiting tasks
 func (tb *taskBucketImpl) length() int {
 	tb.mux.Lock()
-	ln := len(tb.tasks)
+	ln := len(tb.tasks) + tb.pending.Len()
 	tb.mux.Unlock()
 	return ln
 }
 
-func (tb *taskBucketImpl) panic(panic bool) {
-	tb.panicChan <- panic
+//acquire takes a rate limit token and a worker slot before calling OnExecute,
+//it waits while MaxConcurrent executions are running, returns how long it waited
+func (tb *taskBucketImpl) acquire(ctx context.Context, quit <-chan bool) (time.Duration, error) {
+	start := time.Now()
+	if err := tb.limiter.wait(ctx, quit); err != nil {
+		return 0, err
+	}
+	if tb.workers == nil {
+		return time.Since(start), nil
+	}
+	select {
+	case tb.workers <- struct{}{}:
+		return time.Since(start), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-quit:
+		return 0, errors.New("task quit while waiting for worker")
+	}
+}
+
+//release gives back the worker slot
+func (tb *taskBucketImpl) release() {
+	if tb.workers == nil {
+		return
+	}
+	<-tb.workers
+}
+
+//schedule arms the bucket scheduler
+func (tb *taskBucketImpl) schedule(at time.Time, fn func()) *timerEntry {
+	return tb.sched.schedule(at, fn)
+}
+
+//unschedule cancels the armed entry, false when it is already fired
+func (tb *taskBucketImpl) unschedule(e *timerEntry) bool {
+	return tb.sched.cancel(e)
+}
+
+//decode converts the payload sent by the peer
+func (tb *taskBucketImpl) decode(raw []byte) (interface{}, error) {
+	if tb.decoder != nil {
+		return tb.decoder(raw)
+	}
+	var data interface{}
+	err := json.Unmarshal(raw, &data)
+	return data, err
 }
 
 func (tb *taskBucketImpl) log(args ...interface{}) {
-	if tb.config.Verbose {
+	if tb.conf().Verbose {
 		log.Println(args)
 	}
 }
@@ -0,0 +1,59 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+)
+
+//Selector matches the tasks having every given label
+type Selector map[string]string
+
+//Matches checks whether the labels satisfy the selector
+func (s Selector) Matches(labels map[string]string) bool {
+	for k, v := range s {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+//DrainWhere removes every task matching the selector, including the pending queue
+//args:
+//	ctx: passed ctx
+//	sel: label selector, should not be empty
+//returns:
+//	identities of the drained tasks, error when the selector is empty
+func (tb *taskBucketImpl) DrainWhere(ctx context.Context, sel Selector) ([]string, error) {
+	if len(sel) == 0 {
+		return nil, errors.New("unable to drain with empty selector")
+	}
+	return tb.drainMatching(ctx, &TaskFilter{Labels: sel}), nil
+}
+
+//DrainPrefix removes every task which identity starts with the prefix, including the pending queue
+//args:
+//	ctx: passed ctx
+//	prefix: task identity prefix, i.e: order::123::
+//returns:
+//	identities of the drained tasks, error when the prefix is empty
+func (tb *taskBucketImpl) DrainPrefix(ctx context.Context, prefix string) ([]string, error) {
+	if prefix == "" {
+		return nil, errors.New("unable to drain with empty prefix")
+	}
+	return tb.drainMatching(ctx, &TaskFilter{Prefix: prefix}), nil
+}
+
+//drainMatching drains the tasks matching the filter, the tasks ended
+//in the meantime are skipped
+func (tb *taskBucketImpl) drainMatching(ctx context.Context, filter *TaskFilter) []string {
+	var ids []string
+	for _, st := range tb.List(filter) {
+		if err := tb.Drain(ctx, st.ID); err != nil {
+			continue
+		}
+		ids = append(ids, st.ID)
+	}
+	tb.log("task_bucket: drained", len(ids), "task(s)")
+	return ids
+}
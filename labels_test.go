@@ -0,0 +1,83 @@
+package gobucket
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+//labeledBucket holds two time bombs in the bucket and two in the pending queue
+func labeledBucket(t *testing.T) TaskBucket {
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, RunAfter: time.Hour, MaxBucket: 2, MaxPending: 10}, &funcExecutor{})
+	t.Cleanup(tb.(*taskBucketImpl).sched.close)
+	tasks := []struct {
+		id   string
+		team string
+	}{
+		{"order::1::a", "x"},
+		{"order::1::b", "y"},
+		{"order::1::c", "x"},
+		{"order::2::d", "x"},
+	}
+	for _, task := range tasks {
+		err := tb.FillWithOptions(context.Background(), task.id, nil, WithTaskType(TimeBombTask), WithLabels(map[string]string{"team": task.team}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := tb.Stats().Pending; n != 2 {
+		t.Fatal("unexpected pending tasks", n)
+	}
+	return tb
+}
+
+//remaining gets the sorted ids of the tasks left in the bucket and the pending queue
+func remaining(tb TaskBucket) []string {
+	var ids []string
+	for _, st := range tb.List(nil) {
+		ids = append(ids, st.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestDrainWhere(t *testing.T) {
+	tb := labeledBucket(t)
+	if _, err := tb.DrainWhere(context.Background(), nil); err == nil {
+		t.Fatal("expected an error for an empty selector")
+	}
+	ids, err := tb.DrainWhere(context.Background(), Selector{"team": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ids)
+	if want := []string{"order::1::a", "order::1::c", "order::2::d"}; !reflect.DeepEqual(ids, want) {
+		t.Fatal("unexpected drained tasks", ids)
+	}
+	if left := remaining(tb); !reflect.DeepEqual(left, []string{"order::1::b"}) {
+		t.Fatal("unexpected remaining tasks", left)
+	}
+}
+
+func TestDrainPrefix(t *testing.T) {
+	tb := labeledBucket(t)
+	if _, err := tb.DrainPrefix(context.Background(), ""); err == nil {
+		t.Fatal("expected an error for an empty prefix")
+	}
+	ids, err := tb.DrainPrefix(context.Background(), "order::1::")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ids)
+	if want := []string{"order::1::a", "order::1::b", "order::1::c"}; !reflect.DeepEqual(ids, want) {
+		t.Fatal("unexpected drained tasks", ids)
+	}
+	if left := remaining(tb); !reflect.DeepEqual(left, []string{"order::2::d"}) {
+		t.Fatal("unexpected remaining tasks", left)
+	}
+	if st := tb.Stats(); st.Tasks != 1 || st.Pending != 0 {
+		t.Fatal("the remaining task was not promoted", st)
+	}
+}
@@ -0,0 +1,129 @@
+package gobucket
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//lifeContext is the context of a run bounded by the life span of the task,
+//unlike context.WithDeadline its clock can be stopped while the task waits
+//for a worker between the attempts
+type lifeContext struct {
+	context.Context
+	mux      sync.Mutex
+	deadline time.Time
+	left     time.Duration //life span left when frozen
+	frozen   bool
+	timer    *time.Timer
+	done     chan struct{}
+	err      error
+}
+
+//withLifeSpan creates the run context ending at the deadline,
+//or when the parent is done
+func withLifeSpan(parent context.Context, deadline time.Time) (*lifeContext, context.CancelFunc) {
+	c := &lifeContext{
+		Context:  parent,
+		deadline: deadline,
+		done:     make(chan struct{}),
+	}
+	if err := parent.Err(); err != nil {
+		c.end(err)
+		return c, func() {}
+	}
+	if !time.Now().Before(deadline) {
+		c.end(context.DeadlineExceeded)
+		return c, func() {}
+	}
+	c.mux.Lock()
+	c.timer = time.AfterFunc(time.Until(deadline), c.expire)
+	c.mux.Unlock()
+	if parent.Done() != nil {
+		go func() {
+			select {
+			case <-parent.Done():
+				c.end(parent.Err())
+			case <-c.done:
+			}
+		}()
+	}
+	return c, func() { c.end(context.Canceled) }
+}
+
+func (c *lifeContext) Deadline() (time.Time, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.frozen {
+		return time.Now().Add(c.left), true
+	}
+	return c.deadline, true
+}
+
+func (c *lifeContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *lifeContext) Err() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.err
+}
+
+//freeze stops the clock until thaw
+func (c *lifeContext) freeze() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.frozen || c.err != nil {
+		return
+	}
+	c.frozen = true
+	c.left = time.Until(c.deadline)
+	c.timer.Stop()
+}
+
+//thaw restarts the clock with the life span left at freeze,
+//returns the new deadline
+func (c *lifeContext) thaw() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if !c.frozen {
+		return c.deadline
+	}
+	c.frozen = false
+	c.deadline = time.Now().Add(c.left)
+	if c.err == nil {
+		c.timer.Reset(c.left)
+	}
+	return c.deadline
+}
+
+//expire ends the context once the deadline passed, the timer may fire
+//early when the clock was frozen or moved meanwhile
+func (c *lifeContext) expire() {
+	c.mux.Lock()
+	if c.frozen {
+		c.mux.Unlock()
+		return
+	}
+	if left := time.Until(c.deadline); left > 0 {
+		c.timer.Reset(left)
+		c.mux.Unlock()
+		return
+	}
+	c.mux.Unlock()
+	c.end(context.DeadlineExceeded)
+}
+
+func (c *lifeContext) end(err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
@@ -0,0 +1,53 @@
+package gobucket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLifeContextFreeze(t *testing.T) {
+	ctx, cancel := withLifeSpan(context.Background(), time.Now().Add(50*time.Millisecond))
+	defer cancel()
+	ctx.freeze()
+	time.Sleep(80 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("frozen context expired")
+	}
+	deadline := ctx.thaw()
+	if left := time.Until(deadline); left < 30*time.Millisecond {
+		t.Fatal("the frozen time was counted, left", left)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context did not expire after thaw")
+	}
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatal("unexpected error", ctx.Err())
+	}
+}
+
+func TestLifeContextPastDeadline(t *testing.T) {
+	ctx, cancel := withLifeSpan(context.Background(), time.Now().Add(-time.Millisecond))
+	defer cancel()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Fatal("expected the context to be done right away, got", ctx.Err())
+	}
+}
+
+func TestLifeContextParentCancel(t *testing.T) {
+	parent, stop := context.WithCancel(context.Background())
+	ctx, cancel := withLifeSpan(parent, time.Now().Add(time.Hour))
+	defer cancel()
+	ctx.freeze()
+	stop()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context did not follow its parent")
+	}
+	if ctx.Err() != context.Canceled {
+		t.Fatal("unexpected error", ctx.Err())
+	}
+}
@@ -0,0 +1,217 @@
+package gobucket
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+//TaskOption overrides the bucket configuration for a single task
+type TaskOption func(*taskOptions)
+
+//taskOptions holds the per task settings, defaulted from the bucket config
+type taskOptions struct {
+	taskType TaskType
+	lifeSpan time.Duration
+	runAfter time.Duration
+	delayed  bool
+	priority int
+	labels   map[string]string
+	schedule Schedule
+	maxRuns  int
+	endTime  time.Time
+	runAt    time.Time
+	fireAt   time.Time //restored fire time
+	deadline time.Time //restored deadline
+	past     PastPolicy
+	retry    *RetryPolicy
+	maxWait  time.Duration
+	reducer  Reducer
+	partKey  string
+	err      error
+
+	deps      []dependency
+	depPolicy DependencyPolicy
+	forward   forwardFunc
+}
+
+//newTaskOptions applies the options on top of the bucket configuration
+func newTaskOptions(cfg *BucketConfig, opts []TaskOption) (*taskOptions, error) {
+	o := &taskOptions{
+		lifeSpan: cfg.LifeSpan,
+		runAfter: cfg.RunAfter,
+		past:     cfg.PastPolicy,
+		retry:    cfg.Retry,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.err != nil {
+		return nil, o.err
+	}
+	if o.taskType == "" {
+		switch {
+		case o.schedule != nil:
+			o.taskType = RecurringTask
+		case o.delayed:
+			o.taskType = TimeBombTask
+		default:
+			o.taskType = ImmidiateTask
+		}
+	}
+	if !o.runAt.IsZero() && o.runAt.Before(time.Now()) {
+		if o.past == RejectPast {
+			return nil, fmt.Errorf("task scheduled in the past at %s", o.runAt.String())
+		}
+		o.runAt = time.Now()
+	}
+	if o.taskType == RecurringTask && o.schedule == nil {
+		//fallback to bucket RunAfter as fixed interval
+		if o.runAfter <= 0 {
+			return nil, errors.New("recurring task requires a schedule or positive run after")
+		}
+		o.schedule = Every(o.runAfter)
+	}
+	return o, nil
+}
+
+//WithTaskType sets the task type, by default the task is an immidiate task
+//unless a delay is given
+func WithTaskType(tt TaskType) TaskOption {
+	return func(o *taskOptions) {
+		o.taskType = tt
+	}
+}
+
+//withFireTime keeps the fire time and the deadline of a restored task
+func withFireTime(at, deadline time.Time) TaskOption {
+	return func(o *taskOptions) {
+		o.fireAt, o.deadline = at, deadline
+	}
+}
+
+//WithLifeSpan overrides BucketConfig.LifeSpan for the task
+func WithLifeSpan(d time.Duration) TaskOption {
+	return func(o *taskOptions) {
+		o.lifeSpan = d
+	}
+}
+
+//WithRunAfter overrides BucketConfig.RunAfter for the task,
+//a task without explicit type will be treated as TimeBombTask
+func WithRunAfter(d time.Duration) TaskOption {
+	return func(o *taskOptions) {
+		o.runAfter = d
+		o.delayed = true
+	}
+}
+
+//WithRunAt schedules the task at the given wall clock time,
+//the life span is counted from the firing time
+func WithRunAt(t time.Time) TaskOption {
+	return func(o *taskOptions) {
+		o.runAt = t
+		o.delayed = true
+	}
+}
+
+//WithPastPolicy overrides BucketConfig.PastPolicy for the task
+func WithPastPolicy(p PastPolicy) TaskOption {
+	return func(o *taskOptions) {
+		o.past = p
+	}
+}
+
+//WithRetry overrides BucketConfig.Retry for the task, nil disables retry
+func WithRetry(r *RetryPolicy) TaskOption {
+	return func(o *taskOptions) {
+		o.retry = r
+	}
+}
+
+//WithDependsOn starts the task only after the given tasks in the same bucket
+//finished successfully, a parent neither in the bucket nor retained counts as failed
+func WithDependsOn(ids ...string) TaskOption {
+	return WithDependsOnBucket(nil, ids...)
+}
+
+//WithDependsOnBucket starts the task only after the given tasks in another bucket,
+//i.e: one of TaskBucketGroup.GetBucket, finished successfully
+func WithDependsOnBucket(tb TaskBucket, ids ...string) TaskOption {
+	return func(o *taskOptions) {
+		for _, id := range ids {
+			o.deps = append(o.deps, dependency{tb: tb, id: id})
+		}
+	}
+}
+
+//WithDependencyPolicy sets what happens to the task when a parent fails,
+//by default the task is cancelled
+func WithDependencyPolicy(p DependencyPolicy) TaskOption {
+	return func(o *taskOptions) {
+		o.depPolicy = p
+	}
+}
+
+//WithPriority sets the task priority, higher value means more important
+func WithPriority(p int) TaskOption {
+	return func(o *taskOptions) {
+		o.priority = p
+	}
+}
+
+//WithLabels attaches key/value labels to the task
+func WithLabels(labels map[string]string) TaskOption {
+	return func(o *taskOptions) {
+		if o.labels == nil {
+			o.labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			o.labels[k] = v
+		}
+	}
+}
+
+//WithSchedule makes the task recurring using the given schedule
+func WithSchedule(s Schedule) TaskOption {
+	return func(o *taskOptions) {
+		o.schedule = s
+	}
+}
+
+//WithInterval makes the task recurring on a fixed interval
+func WithInterval(d time.Duration) TaskOption {
+	return func(o *taskOptions) {
+		if d <= 0 {
+			o.err = errors.New("recurring interval should be positive")
+			return
+		}
+		o.schedule = Every(d)
+	}
+}
+
+//WithCron makes the task recurring using a cron expression, see ParseCron
+func WithCron(expr string) TaskOption {
+	return func(o *taskOptions) {
+		s, err := ParseCron(expr)
+		if err != nil {
+			o.err = err
+			return
+		}
+		o.schedule = s
+	}
+}
+
+//WithMaxRuns limits how many times a recurring task runs, 0 means unlimited
+func WithMaxRuns(n int) TaskOption {
+	return func(o *taskOptions) {
+		o.maxRuns = n
+	}
+}
+
+//WithEndTime stops a recurring task from firing after t
+func WithEndTime(t time.Time) TaskOption {
+	return func(o *taskOptions) {
+		o.endTime = t
+	}
+}
@@ -0,0 +1,32 @@
+package gobucket
+
+import (
+	"context"
+	"fmt"
+)
+
+//PanicError is the task error when OnExecute panics, the task is failed without retry
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic on execution: %v", p.Value)
+}
+
+//PanicExecutor is an Executor which handles the panic inside OnExecute,
+//a plain Executor gets the *PanicError on OnExecuteError instead
+type PanicExecutor interface {
+	Executor
+	OnExecutePanic(ctx context.Context, id string, data interface{}, value interface{}, stack []byte) error
+}
+
+//onPanic routes the recovered panic to the executor
+func (t *taskImpl) onPanic(ctx context.Context, e Executor, pe *PanicError) error {
+	t.log(t.id, "executed with panic=", fmt.Sprint(pe.Value), " run on panic event")
+	if pex, ok := e.(PanicExecutor); ok {
+		return pex.OnExecutePanic(ctx, t.id, t.data, pe.Value, pe.Stack)
+	}
+	return e.OnExecuteError(ctx, t.id, t.data, pe)
+}
@@ -0,0 +1,120 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func panicking(ctx context.Context, id string, data interface{}) error {
+	if id == "bad" {
+		panic("boom")
+	}
+	return nil
+}
+
+//panicExecutor handles the panic inside OnExecute
+type panicExecutor struct {
+	*funcExecutor
+	values chan interface{}
+}
+
+func (e *panicExecutor) OnExecutePanic(ctx context.Context, id string, data interface{}, value interface{}, stack []byte) error {
+	e.values <- value
+	return nil
+}
+
+func TestPanicDoesNotStopOtherTasksNorRetry(t *testing.T) {
+	e := &funcExecutor{execute: panicking}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Second,
+		MaxBucket: 10,
+		Retry:     &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+	}, e)
+	ctx := context.Background()
+	bad, err := tb.FillFuture(ctx, "bad", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, err := tb.FillFuture(ctx, "good", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := waitResult(bad); !ok || res.State != StateFailed || res.Attempts != 1 {
+		t.Fatal("expected a single failed attempt", res)
+	}
+	if res, ok := waitResult(good); !ok || res.State != StateFinished {
+		t.Fatal("the other task did not finish", res)
+	}
+	runs := 0
+	for _, id := range e.executed() {
+		if id == "bad" {
+			runs++
+		}
+	}
+	if runs != 1 {
+		t.Fatal("the panicking task was retried", runs)
+	}
+}
+
+func TestPanicGoesToOnExecutePanic(t *testing.T) {
+	failed := make(chan error, 1)
+	e := &panicExecutor{
+		funcExecutor: &funcExecutor{
+			execute: panicking,
+			failed: func(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+				failed <- onExecuteErr
+				return nil
+			},
+		},
+		values: make(chan interface{}, 1),
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10}, e)
+	f, err := tb.FillFuture(context.Background(), "bad", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := waitResult(f); !ok {
+		t.Fatal("task did not end")
+	}
+	select {
+	case v := <-e.values:
+		if v != "boom" {
+			t.Fatal("unexpected panic value", v)
+		}
+	default:
+		t.Fatal("OnExecutePanic was not called")
+	}
+	select {
+	case err := <-failed:
+		t.Fatal("OnExecuteError was called", err)
+	default:
+	}
+}
+
+func TestPanicGoesToOnExecuteError(t *testing.T) {
+	failed := make(chan error, 1)
+	e := &funcExecutor{execute: panicking}
+	e.failed = func(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+		failed <- onExecuteErr
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10}, e)
+	f, err := tb.FillFuture(context.Background(), "bad", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := waitResult(f); !ok {
+		t.Fatal("task did not end")
+	}
+	select {
+	case err := <-failed:
+		var pe *PanicError
+		if !errors.As(err, &pe) || pe.Value != "boom" || len(pe.Stack) == 0 {
+			t.Fatal("expected a *PanicError", err)
+		}
+	default:
+		t.Fatal("OnExecuteError was not called")
+	}
+}
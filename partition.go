@@ -0,0 +1,98 @@
+package gobucket
+
+import "time"
+
+//WithPartitionKey runs the tasks sharing the key one at a time in fill order,
+//the tasks with different keys still run in parallel. The life span of a task
+//does not count the time queued behind its predecessors
+func WithPartitionKey(key string) TaskOption {
+	return func(o *taskOptions) {
+		o.partKey = key
+	}
+}
+
+//partition gets the partition key of the task
+func (t *taskImpl) partition() string {
+	return t.partKey
+}
+
+//identity gets the task id
+func (t *taskImpl) identity() string {
+	return t.id
+}
+
+//grant gives the task its turn in the partition
+func (t *taskImpl) grant() {
+	close(t.turn)
+}
+
+//waitTurn blocks until the predecessors in the partition left the bucket,
+//false when the task quit while waiting
+func (t *taskImpl) waitTurn() (time.Duration, bool) {
+	if t.turn == nil {
+		return 0, true
+	}
+	start := time.Now()
+	select {
+	case <-t.turn:
+		return time.Since(start), true
+	case <-t.signalQuit:
+		return 0, false
+	}
+}
+
+//join puts the task at the end of its partition, must be called with lock held
+func (tb *taskBucketImpl) join(t task) {
+	key := t.partition()
+	if key == "" {
+		return
+	}
+	q := append(tb.partitions[key], t)
+	tb.partitions[key] = q
+	if len(q) == 1 {
+		t.grant()
+	}
+}
+
+//leave takes the task out of its partition and gives the turn to the next one,
+//must be called with lock held
+func (tb *taskBucketImpl) leave(t task) {
+	key := t.partition()
+	if key == "" {
+		return
+	}
+	q := tb.partitions[key]
+	for i, pt := range q {
+		if pt != t {
+			continue
+		}
+		q = append(q[:i], q[i+1:]...)
+		if len(q) == 0 {
+			delete(tb.partitions, key)
+		} else {
+			tb.partitions[key] = q
+			if i == 0 {
+				q[0].grant()
+			}
+		}
+		return
+	}
+}
+
+//queuedPredecessor checks whether a task before the given one in its partition
+//is still in the pending queue, must be called with lock held
+func (tb *taskBucketImpl) queuedPredecessor(t task) bool {
+	key := t.partition()
+	if key == "" {
+		return false
+	}
+	for _, pt := range tb.partitions[key] {
+		if pt == t {
+			return false
+		}
+		if _, queued := tb.pending.get(pt.identity()); queued {
+			return true
+		}
+	}
+	return false
+}
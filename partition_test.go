@@ -0,0 +1,109 @@
+package gobucket
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPartitionRunsInFillOrder(t *testing.T) {
+	var mux sync.Mutex
+	running := make(map[string]bool)
+	overlap := false
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		key := data.(string)
+		mux.Lock()
+		if running[key] {
+			overlap = true
+		}
+		running[key] = true
+		mux.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mux.Lock()
+		running[key] = false
+		mux.Unlock()
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 20}, e)
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		key := "k" + strconv.Itoa(i%2)
+		if err := tb.FillWithOptions(ctx, strconv.Itoa(i), key, WithPartitionKey(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := tb.WaitIdle(wctx); err != nil {
+		t.Fatal(err)
+	}
+	if overlap {
+		t.Fatal("tasks of the same partition overlapped")
+	}
+	last := map[int]int{0: -1, 1: -1}
+	for _, id := range e.executed() {
+		i, _ := strconv.Atoi(id)
+		if i < last[i%2] {
+			t.Fatal("partition ran out of fill order", e.executed())
+		}
+		last[i%2] = i
+	}
+}
+
+func TestPartitionWithPriorityOrderDoesNotDeadlock(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(30 * time.Millisecond)}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:   time.Second,
+		MaxBucket:  1,
+		MaxPending: 10,
+		QueueOrder: PriorityOrder,
+	}, e)
+	ctx := context.Background()
+	if err := tb.FillWithOptions(ctx, "x", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.FillWithOptions(ctx, "a", nil, WithPartitionKey("k"), WithPriority(0)); err != nil {
+		t.Fatal(err)
+	}
+	f, err := tb.FillFuture(ctx, "b", nil, WithPartitionKey("k"), WithPriority(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := waitResult(f); !ok || res.State != StateFinished {
+		t.Fatal("partition deadlocked", tb.Stats())
+	}
+	if runs := e.executed(); len(runs) != 3 || runs[1] != "a" || runs[2] != "b" {
+		t.Fatal("unexpected runs", runs)
+	}
+}
+
+func TestPartitionSkipKeepsPriority(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(20 * time.Millisecond)}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:   time.Second,
+		MaxBucket:  1,
+		MaxPending: 10,
+		QueueOrder: PriorityOrder,
+	}, e)
+	ctx := context.Background()
+	tb.FillWithOptions(ctx, "x", nil)
+	tb.FillWithOptions(ctx, "a", nil, WithPartitionKey("k"), WithPriority(0))
+	tb.FillWithOptions(ctx, "b", nil, WithPartitionKey("k"), WithPriority(10))
+	tb.FillWithOptions(ctx, "c", nil, WithPriority(5))
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := tb.WaitIdle(wctx); err != nil {
+		t.Fatal(err, e.executed())
+	}
+	//b is skipped until a left, c keeps its place before a
+	want := []string{"x", "c", "a", "b"}
+	got := e.executed()
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatal("unexpected order", got)
+		}
+	}
+}
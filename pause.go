@@ -0,0 +1,60 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+//Pause stops the tasks from entering OnExecute until Resume, the bucket still
+//accepts new tasks and the delayed tasks keep counting down. The running
+//OnExecute calls are not interrupted
+func (tb *taskBucketImpl) Pause() {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	if tb.paused == nil {
+		tb.paused = make(chan struct{})
+		tb.log("task_bucket: paused")
+	}
+}
+
+//Resume lets the waiting tasks enter OnExecute again
+func (tb *taskBucketImpl) Resume() {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	if tb.paused != nil {
+		close(tb.paused)
+		tb.paused = nil
+		tb.log("task_bucket: resumed")
+	}
+}
+
+//Paused checks whether the bucket is paused
+func (tb *taskBucketImpl) Paused() bool {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	return tb.paused != nil
+}
+
+//waitResume blocks while the bucket is paused, it returns how long the task
+//waited, or the life span to be added when BucketConfig.PauseFreezesLifeSpan is set
+func (tb *taskBucketImpl) waitResume(ctx context.Context, quit <-chan bool) (time.Duration, error) {
+	tb.mux.Lock()
+	paused := tb.paused
+	tb.mux.Unlock()
+	if paused == nil {
+		return 0, nil
+	}
+	start := time.Now()
+	select {
+	case <-paused:
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-quit:
+		return 0, errors.New("task quit while the bucket is paused")
+	}
+	if !tb.conf().PauseFreezesLifeSpan {
+		return 0, nil
+	}
+	return time.Since(start), nil
+}
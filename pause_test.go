@@ -0,0 +1,103 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//pausedRetry pauses the bucket during the backoff of the first attempt
+//and resumes it after the life span would have passed
+func pausedRetry(t *testing.T, freeze bool) *TaskResult {
+	var calls int32
+	var tb TaskBucket
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			tb.Pause()
+			return errors.New("try again")
+		}
+		return nil
+	}
+	tb = NewTaskBucket(&BucketConfig{
+		LifeSpan:             100 * time.Millisecond,
+		MaxBucket:            10,
+		Retry:                &RetryPolicy{MaxAttempts: 2, Backoff: 10 * time.Millisecond},
+		PauseFreezesLifeSpan: freeze,
+	}, e)
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	tb.Resume()
+	res, ok := waitResult(f)
+	if !ok {
+		t.Fatal("task did not end")
+	}
+	return res
+}
+
+func TestPauseFreezesLifeSpanBetweenRetries(t *testing.T) {
+	if res := pausedRetry(t, true); res.State != StateFinished || res.Attempts != 2 {
+		t.Fatal("expected the retry to finish", res)
+	}
+}
+
+func TestPauseCountsLifeSpanBetweenRetries(t *testing.T) {
+	if res := pausedRetry(t, false); res.State != StateExhausted {
+		t.Fatal("expected the task to be exhausted", res)
+	}
+}
+
+func TestPauseHoldsNewTasks(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:             50 * time.Millisecond,
+		MaxBucket:            10,
+		PauseFreezesLifeSpan: true,
+	}, e)
+	tb.Pause()
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if len(e.executed()) != 0 || !tb.Paused() {
+		t.Fatal("task ran while paused")
+	}
+	tb.Resume()
+	if res, ok := waitResult(f); !ok || res.State != StateFinished {
+		t.Fatal("task did not finish after resume", res)
+	}
+}
+
+func TestPauseExhaustsWaitingTaskOnTime(t *testing.T) {
+	exhausted := make(chan string, 1)
+	e := &funcExecutor{}
+	e.exhausted = func(ctx context.Context, id string, data interface{}) error {
+		exhausted <- id
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: 50 * time.Millisecond, MaxBucket: 10}, e)
+	tb.Pause()
+	defer tb.Resume()
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := waitResult(f)
+	if !ok {
+		t.Fatal("the paused task was not exhausted before resume")
+	}
+	if res.State != StateExhausted || len(e.executed()) != 0 {
+		t.Fatal("expected the task to be exhausted without running", res, e.executed())
+	}
+	select {
+	case <-exhausted:
+	default:
+		t.Fatal("OnTaskExhausted was not called")
+	}
+}
@@ -0,0 +1,66 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+//forwardFunc passes the result of a finished task to the next pipeline stage
+type forwardFunc func(ctx context.Context, id string, result interface{}) error
+
+//withForward is used by the pipeline to chain the stage
+func withForward(f forwardFunc) TaskOption {
+	return func(o *taskOptions) {
+		o.forward = f
+	}
+}
+
+//Pipeline chains the buckets of a group, the result of a stage
+//is filled as the data of a new task, with the same id, in the next stage.
+//Each stage keeps its own BucketConfig and Executor hooks
+type Pipeline struct {
+	stages []string
+	bctrl  *bucketsCtrl
+}
+
+//Pipeline creates a pipeline from the bucket names, in order
+func (b *bucketGroup) Pipeline(stages ...string) (*Pipeline, error) {
+	if len(stages) == 0 {
+		return nil, errors.New("pipeline requires at least one stage")
+	}
+	for _, s := range stages {
+		if b.bctrl.get(s) == nil {
+			return nil, fmt.Errorf("unable to find bucket for stage=%s", s)
+		}
+	}
+	return &Pipeline{
+		stages: stages,
+		bctrl:  b.bctrl,
+	}, nil
+}
+
+//Fill puts the task to the first stage, the options are applied to the first stage only
+//args:
+//	ctx: context passed
+//	id: identity of the task in every stage
+//	data: the input of the first stage
+//	opts: per task options
+//returns:
+//	fill operation error
+func (p *Pipeline) Fill(ctx context.Context, id string, data interface{}, opts ...TaskOption) error {
+	return p.fill(ctx, 0, id, data, opts)
+}
+
+func (p *Pipeline) fill(ctx context.Context, stage int, id string, data interface{}, opts []TaskOption) error {
+	tb := p.bctrl.get(p.stages[stage])
+	if tb == nil {
+		return fmt.Errorf("unable to find bucket for stage=%s", p.stages[stage])
+	}
+	if stage+1 < len(p.stages) {
+		opts = append(opts[:len(opts):len(opts)], withForward(func(ctx context.Context, id string, result interface{}) error {
+			return p.fill(ctx, stage+1, id, result, nil)
+		}))
+	}
+	return tb.FillWithOptions(ctx, id, data, opts...)
+}
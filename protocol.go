@@ -70,8 +70,17 @@ func stask(b *bserver, mc *mconn, req *Req) error {
 		return errNotRegistered
 	}
 	b.debug(fmt.Sprintf("server: accept task from %s", mc.addr()))
-	var reqData interface{}
-	err := json.Unmarshal([]byte(req.Data), &reqData)
+	tb := b.ctrl.get(req.Group)
+	if tb == nil {
+		mc.pushRet(&Ret{
+			Cmd:   TASK,
+			PID:   req.PID,
+			Group: req.Group,
+			Err:   fmt.Sprintf("unable to find bucket for task=%s", req.Group),
+		})
+		return fmt.Errorf("unable to find bucket for task=%s", req.Group)
+	}
+	reqData, err := tb.decode([]byte(req.Data))
 	if err != nil {
 		mc.pushRet(&Ret{
 			Cmd:   TASK,
@@ -81,7 +90,7 @@ func stask(b *bserver, mc *mconn, req *Req) error {
 		})
 		return err
 	}
-	err = b.ctrl.get(req.Group).Fill(context.Background(), ImmidiateTask, req.PID, reqData)
+	err = tb.Fill(context.Background(), ImmidiateTask, req.PID, reqData)
 	if err != nil {
 		log.Printf("protocol: unable fill from %s err=%s data=%+v\n", mc.addr(), err.Error(), req)
 		mc.pushRet(&Ret{
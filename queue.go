@@ -0,0 +1,150 @@
+package gobucket
+
+import (
+	"container/heap"
+	"context"
+	"time"
+)
+
+//QueueOrder defines how waiting tasks are taken from the pending queue
+type QueueOrder int
+
+const (
+	FIFOOrder     QueueOrder = iota //first filled, first started
+	PriorityOrder                   //highest priority first, then first filled
+)
+
+//pendingTask is a task waiting for a free slot in the bucket
+type pendingTask struct {
+	ctx      context.Context
+	task     task
+	id       string
+	data     interface{}
+	priority int
+	seq      uint64
+	index    int
+	enqueued time.Time
+	timer    *time.Timer
+}
+
+//pendingQueue holds waiting tasks ordered by QueueOrder
+type pendingQueue struct {
+	items []*pendingTask
+	byID  map[string]*pendingTask
+	order QueueOrder
+	seq   uint64
+}
+
+func newPendingQueue(order QueueOrder) *pendingQueue {
+	return &pendingQueue{
+		byID:  make(map[string]*pendingTask),
+		order: order,
+	}
+}
+
+func (q *pendingQueue) Len() int {
+	return len(q.items)
+}
+
+func (q *pendingQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if q.order == PriorityOrder && a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (q *pendingQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.items[i].index = i
+	q.items[j].index = j
+}
+
+func (q *pendingQueue) Push(x interface{}) {
+	p := x.(*pendingTask)
+	p.index = len(q.items)
+	q.items = append(q.items, p)
+}
+
+func (q *pendingQueue) Pop() interface{} {
+	n := len(q.items)
+	p := q.items[n-1]
+	q.items[n-1] = nil
+	q.items = q.items[:n-1]
+	p.index = -1
+	return p
+}
+
+//push adds the task to the queue
+func (q *pendingQueue) push(p *pendingTask) {
+	q.seq++
+	p.seq = q.seq
+	p.enqueued = time.Now()
+	q.byID[p.id] = p
+	heap.Push(q, p)
+}
+
+//pop takes the next waiting task, nil when empty
+func (q *pendingQueue) pop() *pendingTask {
+	if q.Len() == 0 {
+		return nil
+	}
+	p := heap.Pop(q).(*pendingTask)
+	delete(q.byID, p.id)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	return p
+}
+
+//popWhere takes the next waiting task accepted by ok, the skipped tasks
+//keep their place in the queue, nil when none is accepted
+func (q *pendingQueue) popWhere(ok func(p *pendingTask) bool) *pendingTask {
+	var skipped []*pendingTask
+	var found *pendingTask
+	for q.Len() > 0 {
+		p := heap.Pop(q).(*pendingTask)
+		if ok(p) {
+			found = p
+			break
+		}
+		skipped = append(skipped, p)
+	}
+	for _, p := range skipped {
+		heap.Push(q, p)
+	}
+	if found == nil {
+		return nil
+	}
+	delete(q.byID, found.id)
+	if found.timer != nil {
+		found.timer.Stop()
+	}
+	return found
+}
+
+//ready checks whether the waiting task may be promoted, a task whose partition
+//predecessor or parent is still waiting would hold the slot the other one needs,
+//must be called with lock held
+func (tb *taskBucketImpl) ready(p *pendingTask) bool {
+	return !tb.queuedPredecessor(p.task) && !tb.queuedParent(p.task)
+}
+
+//remove takes out the waiting task by its id
+func (q *pendingQueue) remove(id string) *pendingTask {
+	p, ok := q.byID[id]
+	if !ok {
+		return nil
+	}
+	heap.Remove(q, p.index)
+	delete(q.byID, id)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	return p
+}
+
+func (q *pendingQueue) get(id string) (*pendingTask, bool) {
+	p, ok := q.byID[id]
+	return p, ok
+}
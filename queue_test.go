@@ -0,0 +1,79 @@
+package gobucket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueueTimeoutRunsHookBeforeSettling(t *testing.T) {
+	var settledEarly bool
+	futures := make(chan *Future, 1)
+	hooked := make(chan struct{})
+	e := &funcExecutor{execute: sleeping(200 * time.Millisecond)}
+	e.exhausted = func(ctx context.Context, id string, data interface{}) error {
+		select {
+		case <-(<-futures).Done():
+			settledEarly = true
+		default:
+		}
+		close(hooked)
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:     time.Second,
+		MaxBucket:    1,
+		MaxPending:   1,
+		QueueTimeout: 30 * time.Millisecond,
+	}, e)
+	ctx := context.Background()
+	if err := tb.Fill(ctx, ImmidiateTask, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	f, err := tb.FillFuture(ctx, "b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	futures <- f
+	res, ok := waitResult(f)
+	if !ok {
+		t.Fatal("pending task did not expire")
+	}
+	<-hooked
+	if settledEarly {
+		t.Fatal("future completed before OnTaskExhausted")
+	}
+	if res.State != StateExhausted {
+		t.Fatal("unexpected state", res.State)
+	}
+}
+
+func TestPriorityOrderPromotesHighestFirst(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(30 * time.Millisecond)}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:   time.Second,
+		MaxBucket:  1,
+		MaxPending: 3,
+		QueueOrder: PriorityOrder,
+	}, e)
+	ctx := context.Background()
+	tb.FillWithOptions(ctx, "a", nil)
+	tb.FillWithOptions(ctx, "b", nil, WithPriority(1))
+	tb.FillWithOptions(ctx, "c", nil, WithPriority(5))
+	tb.FillWithOptions(ctx, "d", nil)
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := tb.WaitIdle(wctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "c", "b", "d"}
+	got := e.executed()
+	if len(got) != len(want) {
+		t.Fatal("unexpected runs", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatal("unexpected order", got)
+		}
+	}
+}
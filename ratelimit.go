@@ -0,0 +1,102 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+//rateLimiter is a token bucket limiting the OnExecute starts per second
+type rateLimiter struct {
+	mux     sync.Mutex
+	rate    float64 //tokens per second, 0 means no limit
+	burst   int
+	tokens  float64
+	last    time.Time
+	changed chan struct{}
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	l := &rateLimiter{changed: make(chan struct{})}
+	l.set(rate, burst)
+	return l
+}
+
+//set changes the limit, the waiting tasks re-evaluate their wait
+func (l *rateLimiter) set(rate float64, burst int) {
+	if burst <= 0 {
+		burst = 1
+	}
+	l.mux.Lock()
+	if l.last.IsZero() {
+		//a new limiter starts with a full bucket
+		l.tokens, l.last = float64(burst), time.Now()
+	} else {
+		l.refill(time.Now())
+	}
+	l.rate, l.burst = rate, burst
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+	close(l.changed)
+	l.changed = make(chan struct{})
+	l.mux.Unlock()
+}
+
+//refill adds the tokens earned since the last call, must be called with lock held
+func (l *rateLimiter) refill(now time.Time) {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+}
+
+//wait takes a token, it blocks until a token is available
+func (l *rateLimiter) wait(ctx context.Context, quit <-chan bool) error {
+	for {
+		l.mux.Lock()
+		if l.rate <= 0 {
+			l.mux.Unlock()
+			return nil
+		}
+		l.refill(time.Now())
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mux.Unlock()
+			return nil
+		}
+		d := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		changed := l.changed
+		l.mux.Unlock()
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-changed:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-quit:
+			timer.Stop()
+			return errors.New("task quit while waiting for rate limit")
+		}
+	}
+}
+
+//SetRateLimit changes how many OnExecute calls may start per second,
+//the tasks over the limit are delayed
+//args:
+//	perSecond: OnExecute starts per second, 0 means no limit
+//	burst: starts allowed at once, default 1
+func (tb *taskBucketImpl) SetRateLimit(perSecond float64, burst int) {
+	tb.mux.Lock()
+	//keep the configuration in step, Reconfigure compares against it
+	c := *tb.conf()
+	c.RateLimit, c.RateBurst = perSecond, burst
+	tb.config.Store(&c)
+	tb.limiter.set(perSecond, burst)
+	tb.mux.Unlock()
+	tb.log("task_bucket: rate limit set to", perSecond, "per second, burst", burst)
+}
@@ -0,0 +1,64 @@
+package gobucket
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitDelaysWithoutExhausting(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  50 * time.Millisecond,
+		MaxBucket: 10,
+		RateLimit: 20,
+	}, e)
+	ctx := context.Background()
+	start := time.Now()
+	var fs []*Future
+	for i := 0; i < 5; i++ {
+		f, err := tb.FillFuture(ctx, strconv.Itoa(i), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs = append(fs, f)
+	}
+	for _, f := range fs {
+		res, ok := waitResult(f)
+		if !ok || res.State != StateFinished {
+			t.Fatal("task did not finish", f.ID(), res)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatal("starts were not limited, took", elapsed)
+	}
+}
+
+func TestSetRateLimitWakesWaitingTasks(t *testing.T) {
+	e := &funcExecutor{}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Second,
+		MaxBucket: 10,
+		RateLimit: 0.1,
+	}, e)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if err := tb.Fill(ctx, ImmidiateTask, strconv.Itoa(i), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(e.executed()); n != 1 {
+		t.Fatal("expected only the burst to start, got", n)
+	}
+	tb.SetRateLimit(0, 0)
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := tb.WaitIdle(wctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(e.executed()); n != 3 {
+		t.Fatal("expected every task to run, got", n)
+	}
+}
@@ -0,0 +1,85 @@
+package gobucket
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+//conf gets the current bucket configuration, it must not be modified
+func (tb *taskBucketImpl) conf() *BucketConfig {
+	return tb.config.Load().(*BucketConfig)
+}
+
+//Reconfigure changes the configuration of a live bucket. The tasks already in
+//the bucket keep the life span, delay, retry policy and verbosity they were
+//filled with, the new values apply to the tasks filled afterwards. A larger
+//MaxBucket promotes the waiting tasks right away, a smaller one lets the tasks
+//in the bucket finish and holds the new fills until there is room again.
+//MaxConcurrent, Dispatchers, Store, EventBuffer, EventDrop, DedupeWindow,
+//DedupeSize and QueueOrder can not be changed
+//args:
+//	cfg: the new configuration, it is copied
+//returns:
+//	error when cfg is nil or a field which can not be changed differs
+func (tb *taskBucketImpl) Reconfigure(cfg *BucketConfig) error {
+	if cfg == nil {
+		return errors.New("unable to reconfigure with a nil config")
+	}
+	tb.mux.Lock()
+	cur := tb.conf()
+	if err := fixedConfig(cur, cfg); err != nil {
+		tb.mux.Unlock()
+		return err
+	}
+	c := *cfg
+	tb.config.Store(&c)
+	if cfg.RateLimit != cur.RateLimit || cfg.RateBurst != cur.RateBurst {
+		tb.limiter.set(cfg.RateLimit, cfg.RateBurst)
+	}
+	ps := tb.promote()
+	tb.notify()
+	tb.mux.Unlock()
+	tb.log("task_bucket: reconfigured, max=", cfg.MaxBucket, "life span=", cfg.LifeSpan.String(), "run after=", cfg.RunAfter.String())
+	for _, p := range ps {
+		p.task.start()
+	}
+	return nil
+}
+
+//fixedConfig checks the fields which can not be changed on a live bucket
+func fixedConfig(cur, cfg *BucketConfig) error {
+	var field string
+	switch {
+	case cur.MaxConcurrent != cfg.MaxConcurrent:
+		field = "MaxConcurrent"
+	case cur.Dispatchers != cfg.Dispatchers:
+		field = "Dispatchers"
+	case !sameStore(cur.Store, cfg.Store):
+		field = "Store"
+	case cur.EventBuffer != cfg.EventBuffer, cur.EventDrop != cfg.EventDrop:
+		field = "EventBuffer/EventDrop"
+	case cur.DedupeWindow != cfg.DedupeWindow, cur.DedupeSize != cfg.DedupeSize:
+		field = "DedupeWindow/DedupeSize"
+	case cur.QueueOrder != cfg.QueueOrder:
+		field = "QueueOrder"
+	default:
+		return nil
+	}
+	return fmt.Errorf("unable to reconfigure %s on a live bucket", field)
+}
+
+//sameStore compares the stores without panicking on a non comparable store type
+func sameStore(a, b Store) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta := reflect.TypeOf(a)
+	if ta != reflect.TypeOf(b) {
+		return false
+	}
+	if ta.Comparable() {
+		return a == b
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,106 @@
+package gobucket
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReconfigureRejectsNil(t *testing.T) {
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 1}, &funcExecutor{})
+	defer tb.(*taskBucketImpl).sched.close()
+	if err := tb.Reconfigure(nil); err == nil {
+		t.Fatal("expected an error for a nil config")
+	}
+}
+
+func TestReconfigureRejectsFixedField(t *testing.T) {
+	cfg := &BucketConfig{LifeSpan: time.Second, MaxBucket: 1, MaxConcurrent: 2}
+	tb := NewTaskBucket(cfg, &funcExecutor{})
+	defer tb.(*taskBucketImpl).sched.close()
+	c := *cfg
+	c.MaxConcurrent = 4
+	if err := tb.Reconfigure(&c); err == nil {
+		t.Fatal("expected an error for a changed MaxConcurrent")
+	}
+	if n := tb.conf().MaxConcurrent; n != 2 {
+		t.Fatal("the rejected config was applied", n)
+	}
+}
+
+//taggedStore is a store value which can not be compared with ==
+type taggedStore struct {
+	Store
+	tags []string
+}
+
+func TestReconfigureNonComparableStore(t *testing.T) {
+	store := taggedStore{openStore(t, filepath.Join(t.TempDir(), "tasks.wal")), []string{"a"}}
+	cfg := &BucketConfig{LifeSpan: time.Second, MaxBucket: 1, Store: store}
+	tb := NewTaskBucket(cfg, &funcExecutor{})
+	defer tb.(*taskBucketImpl).sched.close()
+	c := *cfg
+	c.MaxBucket = 2
+	if err := tb.Reconfigure(&c); err != nil {
+		t.Fatal("the same store was rejected", err)
+	}
+	c.Store = taggedStore{store.Store, []string{"b"}}
+	if err := tb.Reconfigure(&c); err == nil {
+		t.Fatal("expected an error for a changed Store")
+	}
+}
+
+func TestReconfigureReplacesSetRateLimit(t *testing.T) {
+	cfg := &BucketConfig{LifeSpan: time.Second, MaxBucket: 1}
+	tb := NewTaskBucket(cfg, &funcExecutor{})
+	defer tb.(*taskBucketImpl).sched.close()
+	tb.SetRateLimit(1, 1)
+	if err := tb.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	l := tb.(*taskBucketImpl).limiter
+	l.mux.Lock()
+	rate := l.rate
+	l.mux.Unlock()
+	if rate != 0 {
+		t.Fatal("the rate limit set at runtime was kept", rate)
+	}
+}
+
+func TestReconfigureLargerMaxBucketPromotes(t *testing.T) {
+	release := make(chan struct{})
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		<-release
+		return nil
+	}
+	cfg := &BucketConfig{LifeSpan: time.Second, MaxBucket: 1, MaxPending: 10}
+	tb := NewTaskBucket(cfg, e)
+	defer tb.(*taskBucketImpl).sched.close()
+	defer close(release)
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := tb.Fill(ctx, ImmidiateTask, id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := tb.Stats().Pending; n != 2 {
+		t.Fatal("unexpected pending tasks", n)
+	}
+	c := *cfg
+	c.MaxBucket = 3
+	if err := tb.Reconfigure(&c); err != nil {
+		t.Fatal(err)
+	}
+	if n := tb.Stats().Pending; n != 0 {
+		t.Fatal("the waiting tasks were not promoted", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(e.executed()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := len(e.executed()); n != 3 {
+		t.Fatal("the promoted tasks did not run", n)
+	}
+}
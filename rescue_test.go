@@ -0,0 +1,142 @@
+package gobucket
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//slowStore blocks Save until release is closed
+type slowStore struct {
+	Store
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) Save(t *StoredTask) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.Store.Save(t)
+}
+
+func TestRescueTaskNotStartedYet(t *testing.T) {
+	var panics int32
+	e := &funcExecutor{}
+	e.panicked = func(ctx context.Context, id string, data interface{}) error {
+		atomic.AddInt32(&panics, 1)
+		return nil
+	}
+	store := &slowStore{
+		Store:   openStore(t, filepath.Join(t.TempDir(), "tasks.wal")),
+		saving:  make(chan struct{}, 1),
+		release: make(chan struct{}),
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10, Store: store}, e)
+	filled := make(chan error, 1)
+	go func() {
+		filled <- tb.Fill(context.Background(), ImmidiateTask, "a", "payload")
+	}()
+	<-store.saving
+	//the task is in the bucket but not started while its save is in progress,
+	//the deletion by Rescue is written after the save
+	time.AfterFunc(50*time.Millisecond, func() { close(store.release) })
+	rescued, err := tb.Rescue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rescued) != 1 || rescued[0].ID != "a" || rescued[0].Data != "payload" {
+		t.Fatal("unexpected rescued tasks", rescued)
+	}
+	if err := <-filled; err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(e.executed()) != 0 || atomic.LoadInt32(&panics) != 1 {
+		t.Fatal("the rescued task ran", e.executed(), panics)
+	}
+}
+
+func TestRescueRunningAndWaitingTasks(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(time.Hour)}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:   time.Hour,
+		RunAfter:   time.Hour,
+		MaxBucket:  2,
+		MaxPending: 1,
+	}, e)
+	ctx := context.Background()
+	tb.Fill(ctx, ImmidiateTask, "running", 1)
+	tb.Fill(ctx, TimeBombTask, "bomb", 2)
+	tb.Fill(ctx, ImmidiateTask, "pending", 3)
+	time.Sleep(20 * time.Millisecond)
+	rctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	rescued, err := tb.Rescue(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]TaskState)
+	for _, r := range rescued {
+		states[r.ID] = r.State
+	}
+	if len(states) != 3 || states["running"] != StateRunning || states["bomb"] != StateScheduled {
+		t.Fatal("unexpected rescued tasks", states)
+	}
+	if tb.length() != 0 {
+		t.Fatal("the bucket was not emptied", tb.length())
+	}
+}
+
+func TestRescueTaskFinishingMeanwhile(t *testing.T) {
+	finishing := make(chan struct{})
+	e := &funcExecutor{}
+	e.finish = func(ctx context.Context, id string, data interface{}) error {
+		close(finishing)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10}, e)
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-finishing
+	rctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	rescued, err := tb.Rescue(rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rescued) != 0 {
+		t.Fatal("the finished task was rescued", rescued)
+	}
+	if res, ok := waitResult(f); !ok || res.State != StateFinished {
+		t.Fatal("the task did not finish", res)
+	}
+}
+
+func TestRescueHonorsDeadline(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(time.Hour)}
+	e.panicked = func(ctx context.Context, id string, data interface{}) error {
+		time.Sleep(500 * time.Millisecond)
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Hour, MaxBucket: 10}, e)
+	tb.Fill(context.Background(), ImmidiateTask, "a", nil)
+	time.Sleep(20 * time.Millisecond)
+	rctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	rescued, err := tb.Rescue(rctx)
+	if err != context.DeadlineExceeded {
+		t.Fatal("expected the deadline error, got", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Fatal("Rescue did not honor the deadline, took", elapsed)
+	}
+	if len(rescued) != 1 {
+		t.Fatal("unexpected rescued tasks", rescued)
+	}
+}
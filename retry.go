@@ -0,0 +1,120 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+type attemptKey struct{}
+
+//RetryPolicy defines how a failed OnExecute is retried,
+//every attempt is still bounded by the task life span
+type RetryPolicy struct {
+	MaxAttempts int           //total attempts including the first one
+	Backoff     time.Duration //delay before the second attempt
+	MaxBackoff  time.Duration //upper bound of the delay, 0 means no bound
+	Multiplier  float64       //backoff growth per attempt, default 2
+	Jitter      float64       //fraction of the delay randomized, 0..1
+}
+
+//next decides whether the attempt should be retried and how long to wait
+func (r *RetryPolicy) next(attempt int, err error) (time.Duration, bool) {
+	if r == nil || err == nil || IsPermanent(err) {
+		return 0, false
+	}
+	if attempt >= r.MaxAttempts {
+		return 0, false
+	}
+	var ra *retryAfterError
+	if errors.As(err, &ra) {
+		return ra.after, true
+	}
+	return r.backoff(attempt), true
+}
+
+//backoff calculates exponential delay with jitter after the given attempt
+func (r *RetryPolicy) backoff(attempt int) time.Duration {
+	mult := r.Multiplier
+	if mult <= 0 {
+		mult = 2
+	}
+	d := float64(r.Backoff)
+	for i := 1; i < attempt; i++ {
+		d *= mult
+		if r.MaxBackoff > 0 && d > float64(r.MaxBackoff) {
+			break
+		}
+	}
+	if r.MaxBackoff > 0 && d > float64(r.MaxBackoff) {
+		d = float64(r.MaxBackoff)
+	}
+	if r.Jitter > 0 {
+		d += d * r.Jitter * (rand.Float64()*2 - 1)
+	}
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(d)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string {
+	return p.err.Error()
+}
+
+func (p *permanentError) Unwrap() error {
+	return p.err
+}
+
+//Permanent marks the error as permanent, the task will not be retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+//IsPermanent checks whether the error is marked as permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (r *retryAfterError) Error() string {
+	return r.err.Error()
+}
+
+func (r *retryAfterError) Unwrap() error {
+	return r.err
+}
+
+//RetryAfter asks the bucket to retry the task after d instead of the policy backoff,
+//the retry still requires a RetryPolicy with attempts left
+func RetryAfter(err error, d time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	return &retryAfterError{err: err, after: d}
+}
+
+//Attempt returns the current attempt number (starts at 1) from the OnExecute context
+func Attempt(ctx context.Context) int {
+	if a, ok := ctx.Value(attemptKey{}).(int); ok {
+		return a
+	}
+	return 0
+}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
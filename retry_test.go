@@ -0,0 +1,127 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffGrowsUpToMax(t *testing.T) {
+	r := &RetryPolicy{MaxAttempts: 10, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if d := r.backoff(i + 1); d != w*time.Millisecond {
+			t.Fatal("unexpected backoff after attempt", i+1, d)
+		}
+	}
+	r.Multiplier = 3
+	if d := r.backoff(2); d != 30*time.Millisecond {
+		t.Fatal("the multiplier was not applied", d)
+	}
+}
+
+func TestRetryNext(t *testing.T) {
+	r := &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	err := errors.New("failed")
+	if _, ok := r.next(1, Permanent(err)); ok {
+		t.Fatal("a permanent error was retried")
+	}
+	if _, ok := r.next(3, err); ok {
+		t.Fatal("retried after the last attempt")
+	}
+	if d, ok := r.next(1, RetryAfter(err, time.Minute)); !ok || d != time.Minute {
+		t.Fatal("RetryAfter did not override the backoff", d, ok)
+	}
+	if _, ok := r.next(3, RetryAfter(err, time.Minute)); ok {
+		t.Fatal("RetryAfter was retried without attempts left")
+	}
+}
+
+func TestRetryPermanentErrorIsNotRetried(t *testing.T) {
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		return Permanent(errors.New("bad payload"))
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Second,
+		MaxBucket: 10,
+		Retry:     &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+	}, e)
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := waitResult(f)
+	if !ok || res.State != StateFailed || res.Attempts != 1 {
+		t.Fatal("expected a single failed attempt", res)
+	}
+}
+
+func TestRetryAttemptInContext(t *testing.T) {
+	var mux sync.Mutex
+	var attempts []int
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		mux.Lock()
+		defer mux.Unlock()
+		attempts = append(attempts, Attempt(ctx))
+		if len(attempts) < 3 {
+			return errors.New("try again")
+		}
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Second,
+		MaxBucket: 10,
+		Retry:     &RetryPolicy{MaxAttempts: 5, Backoff: time.Millisecond},
+	}, e)
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := waitResult(f)
+	if !ok || res.State != StateFinished || res.Attempts != 3 {
+		t.Fatal("expected the third attempt to finish", res)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if !reflect.DeepEqual(attempts, []int{1, 2, 3}) {
+		t.Fatal("unexpected attempt numbers", attempts)
+	}
+}
+
+func TestRetryOnExecuteErrorAfterLastAttempt(t *testing.T) {
+	var mux sync.Mutex
+	var failures []int
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		return errors.New("failed")
+	}
+	e.failed = func(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+		mux.Lock()
+		failures = append(failures, len(e.executed()))
+		mux.Unlock()
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Second,
+		MaxBucket: 10,
+		Retry:     &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+	}, e)
+	f, err := tb.FillFuture(context.Background(), "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := waitResult(f)
+	if !ok || res.State != StateFailed || res.Attempts != 3 {
+		t.Fatal("expected three failed attempts", res)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if !reflect.DeepEqual(failures, []int{3}) {
+		t.Fatal("OnExecuteError was not called once after the last attempt", failures)
+	}
+}
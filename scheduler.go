@@ -0,0 +1,191 @@
+package gobucket
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDispatchers = 4
+	maxFireBatch       = 1024
+)
+
+//timerEntry is a callback waiting in the scheduler
+type timerEntry struct {
+	at    time.Time
+	fn    func()
+	seq   uint64
+	index int
+}
+
+//timerHeap orders the entries by fire time, then by schedule order
+type timerHeap []*timerEntry
+
+func (h timerHeap) Len() int {
+	return len(h)
+}
+
+func (h timerHeap) Less(i, j int) bool {
+	if h[i].at.Equal(h[j].at) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].at.Before(h[j].at)
+}
+
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x interface{}) {
+	e := x.(*timerEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *timerHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+//scheduler fires the callbacks at their time using a single timer over a min-heap,
+//so a waiting task does not hold any goroutine. The callbacks are run
+//by a small dispatcher pool and are expected to return quickly
+type scheduler struct {
+	mux      sync.Mutex
+	entries  timerHeap
+	seq      uint64
+	wake     chan struct{}
+	fire     chan func()
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newScheduler(dispatchers int) *scheduler {
+	if dispatchers <= 0 {
+		dispatchers = defaultDispatchers
+	}
+	s := &scheduler{
+		wake: make(chan struct{}, 1),
+		fire: make(chan func(), maxFireBatch),
+		stop: make(chan struct{}),
+	}
+	go s.loop()
+	for i := 0; i < dispatchers; i++ {
+		go s.dispatch()
+	}
+	return s
+}
+
+//schedule registers fn to be called at the given time
+func (s *scheduler) schedule(at time.Time, fn func()) *timerEntry {
+	s.mux.Lock()
+	s.seq++
+	e := &timerEntry{
+		at:  at,
+		fn:  fn,
+		seq: s.seq,
+	}
+	heap.Push(&s.entries, e)
+	head := s.entries[0] == e
+	s.mux.Unlock()
+	if head {
+		s.notify()
+	}
+	return e
+}
+
+//cancel removes the entry, returns false when it is already fired
+func (s *scheduler) cancel(e *timerEntry) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if e == nil || e.index < 0 {
+		return false
+	}
+	heap.Remove(&s.entries, e.index)
+	return true
+}
+
+//len gets the number of waiting entries
+func (s *scheduler) len() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return len(s.entries)
+}
+
+//close stops the scheduler, the waiting entries are never fired
+func (s *scheduler) close() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) loop() {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		s.mux.Lock()
+		now := time.Now()
+		var due []func()
+		for len(s.entries) > 0 && len(due) < maxFireBatch && !s.entries[0].at.After(now) {
+			e := heap.Pop(&s.entries).(*timerEntry)
+			due = append(due, e.fn)
+		}
+		wait := time.Duration(-1)
+		if len(s.entries) > 0 {
+			wait = s.entries[0].at.Sub(now)
+		}
+		s.mux.Unlock()
+		for _, fn := range due {
+			select {
+			case s.fire <- fn:
+			case <-s.stop:
+				return
+			}
+		}
+		if len(due) > 0 {
+			continue
+		}
+		if wait >= 0 {
+			timer.Reset(wait)
+		}
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (s *scheduler) dispatch() {
+	for {
+		select {
+		case fn := <-s.fire:
+			fn()
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package gobucket
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const accuracyTasks = 1000000
+
+type nopExecutor struct{}
+
+func (nopExecutor) OnExecute(ctx context.Context, id string, data interface{}) error {
+	return nil
+}
+
+func (nopExecutor) OnFinish(ctx context.Context, id string, data interface{}) error {
+	return nil
+}
+
+func (nopExecutor) OnTaskExhausted(ctx context.Context, id string, data interface{}) error {
+	return nil
+}
+
+func (nopExecutor) OnExecuteError(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+	return nil
+}
+
+func (nopExecutor) OnPanic(ctx context.Context, id string, data interface{}) error {
+	return nil
+}
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+//BenchmarkBucketPendingTimeBomb reports the memory and goroutines held by a waiting time bomb,
+//run with -benchtime=1000000x to measure 1M pending tasks
+func TestSchedulerCancel(t *testing.T) {
+	s := newScheduler(1)
+	defer s.close()
+	fired := make(chan int, 2)
+	e := s.schedule(time.Now().Add(30*time.Millisecond), func() { fired <- 1 })
+	if !s.cancel(e) || s.len() != 0 {
+		t.Fatal("the entry was not cancelled", s.len())
+	}
+	if s.cancel(e) || s.cancel(nil) {
+		t.Fatal("cancelled an entry which is not waiting")
+	}
+	e = s.schedule(time.Now(), func() { fired <- 2 })
+	select {
+	case n := <-fired:
+		if n != 2 {
+			t.Fatal("the cancelled entry fired")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the entry did not fire")
+	}
+	if s.cancel(e) {
+		t.Fatal("cancelled a fired entry")
+	}
+	select {
+	case <-fired:
+		t.Fatal("the cancelled entry fired")
+	case <-time.After(60 * time.Millisecond):
+	}
+}
+
+func TestSchedulerSameTimeInScheduleOrder(t *testing.T) {
+	s := newScheduler(1)
+	defer s.close()
+	const n = 100
+	fired := make(chan int, n)
+	at := time.Now().Add(20 * time.Millisecond)
+	for i := 0; i < n; i++ {
+		i := i
+		s.schedule(at, func() { fired <- i })
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case got := <-fired:
+			if got != i {
+				t.Fatal("fired out of schedule order, expected", i, "got", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("the entry did not fire", i)
+		}
+	}
+}
+
+func TestSchedulerWakesForEarlierEntry(t *testing.T) {
+	s := newScheduler(1)
+	defer s.close()
+	fired := make(chan string, 3)
+	late := s.schedule(time.Now().Add(time.Hour), func() { fired <- "late" })
+	//let the loop wait on the timer of the late entry
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	s.schedule(start.Add(20*time.Millisecond), func() { fired <- "early" })
+	select {
+	case id := <-fired:
+		if id != "early" {
+			t.Fatal("unexpected entry fired", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the loop was not woken by the earlier entry")
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Fatal("the entry fired early", d)
+	}
+	//the timer is re-armed for the remaining entries
+	s.cancel(late)
+	first := s.schedule(time.Now().Add(20*time.Millisecond), func() { fired <- "first" })
+	s.schedule(time.Now().Add(40*time.Millisecond), func() { fired <- "second" })
+	s.cancel(first)
+	select {
+	case id := <-fired:
+		if id != "second" {
+			t.Fatal("unexpected entry fired", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the timer was not re-armed after cancelling the head")
+	}
+}
+
+func BenchmarkBucketPendingTimeBomb(b *testing.B) {
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:  time.Hour * 2,
+		RunAfter:  time.Hour,
+		MaxBucket: b.N,
+	}, nopExecutor{})
+	ids := make([]string, b.N)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	ctx := context.Background()
+	goroutines := runtime.NumGoroutine()
+	before := heapAlloc()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tb.Fill(ctx, TimeBombTask, ids[i], nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	after := heapAlloc()
+	b.ReportMetric(float64(after-before)/float64(b.N), "B/task")
+	b.ReportMetric(float64(runtime.NumGoroutine()-goroutines), "goroutines")
+	tb.(*taskBucketImpl).sched.close()
+}
+
+//BenchmarkSchedulerFireAccuracy schedules 1M entries over 2 seconds
+//and reports how late they are fired
+func BenchmarkSchedulerFireAccuracy(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		s := newScheduler(defaultDispatchers)
+		lateness := make([]time.Duration, accuracyTasks)
+		var wg sync.WaitGroup
+		wg.Add(accuracyTasks)
+		start := time.Now().Add(time.Second)
+		for i := 0; i < accuracyTasks; i++ {
+			i := i
+			at := start.Add(time.Duration(i) * 2 * time.Second / accuracyTasks)
+			s.schedule(at, func() {
+				lateness[i] = time.Since(at)
+				wg.Done()
+			})
+		}
+		wg.Wait()
+		s.close()
+		sort.Slice(lateness, func(i, j int) bool {
+			return lateness[i] < lateness[j]
+		})
+		b.ReportMetric(float64(lateness[accuracyTasks/2].Microseconds()), "p50-late-us")
+		b.ReportMetric(float64(lateness[accuracyTasks*99/100].Microseconds()), "p99-late-us")
+		b.ReportMetric(float64(lateness[accuracyTasks-1].Microseconds()), "max-late-us")
+	}
+}
@@ -0,0 +1,140 @@
+package gobucket
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+//ErrBucketClosed is returned by Fill after the bucket is shut down
+var ErrBucketClosed = errors.New("task bucket is shut down")
+
+//HandoffFunc receives a task which has not started when the bucket shuts down,
+//i.e: to re-queue it somewhere else
+type HandoffFunc func(st *TaskStatus, data interface{})
+
+type handoffTask struct {
+	st   *TaskStatus
+	data interface{}
+}
+
+//Shutdown stops accepting new tasks and waits for the running tasks until the
+//context is done, the tasks which have not started (time bombs, scheduled,
+//waiting for the parents or in the pending queue) are drained and given to handoff.
+//A running recurring task ends after its current run. The running tasks left
+//when the context is done are drained.
+//With BucketConfig.Store, the drained tasks stay in the store to be restored
+//by the next NewTaskBucket, except the ones given to a non nil handoff
+//args:
+//	ctx: context passed, its deadline bounds the wait
+//	handoff: receives the tasks which have not started, can be nil
+//returns:
+//	context error when the running tasks did not finish in time
+func (tb *taskBucketImpl) Shutdown(ctx context.Context, handoff HandoffFunc) error {
+	tb.mux.Lock()
+	if tb.closed {
+		tb.mux.Unlock()
+		return ErrBucketClosed
+	}
+	tb.closed = true
+	tb.keepStored = handoff == nil
+	var handed []handoffTask
+	var settled []func()
+	for p := tb.pending.pop(); p != nil; p = tb.pending.pop() {
+		handed = append(handed, handoffTask{st: pendingStatus(p), data: p.data})
+		settled = append(settled, tb.settle(p.task, pendingResult(p, StateDrained, ErrTaskDrained)))
+	}
+	var stopped []task
+	for _, t := range tb.tasks {
+		st := t.status()
+		if t.stop(StateDrained) {
+			stopped = append(stopped, t)
+			handed = append(handed, handoffTask{st: st, data: t.payload()})
+		}
+	}
+	tb.notify()
+	tb.mux.Unlock()
+	for _, fn := range settled {
+		fn()
+	}
+	for _, t := range stopped {
+		t.drain(ctx, false)
+	}
+	tb.log("task_bucket: shutting down, handed off", len(handed), "task(s)")
+	if handoff != nil {
+		for _, h := range handed {
+			handoff(h.st, h.data)
+		}
+	}
+	err := tb.WaitIdle(ctx)
+	if err != nil {
+		tb.mux.Lock()
+		//the interrupted tasks run again after restore
+		tb.keepStored = true
+		running := make([]task, 0, len(tb.tasks))
+		for _, t := range tb.tasks {
+			running = append(running, t)
+		}
+		tb.mux.Unlock()
+		tb.log("task_bucket: shutdown timed out, draining", len(running), "running task(s)")
+		for _, t := range running {
+			t.drain(ctx, true)
+		}
+	}
+	tb.sched.close()
+	return err
+}
+
+//isClosed checks whether Shutdown was called
+func (tb *taskBucketImpl) isClosed() bool {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	return tb.closed
+}
+
+//WaitIdle blocks until the bucket has no task, including the pending queue
+//args:
+//	ctx: context passed, cancel it to stop waiting
+//returns:
+//	context error when it is done before the bucket is idle
+func (tb *taskBucketImpl) WaitIdle(ctx context.Context) error {
+	for {
+		tb.mux.Lock()
+		idle := len(tb.tasks)+tb.pending.Len() == 0
+		freed := tb.freed
+		tb.mux.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//ShutdownOnSignal shuts the bucket down once SIGINT or SIGTERM is received
+//args:
+//	tb: task bucket
+//	timeout: max wait for the running tasks
+//	handoff: receives the tasks which have not started, can be nil
+//returns:
+//	channel receiving the shutdown result
+func ShutdownOnSignal(tb TaskBucket, timeout time.Duration, handoff HandoffFunc) <-chan error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() {
+		<-sigs
+		signal.Stop(sigs)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		done <- tb.Shutdown(ctx, handoff)
+		close(done)
+	}()
+	return done
+}
@@ -0,0 +1,65 @@
+package gobucket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownStopsRunningRecurringTask(t *testing.T) {
+	started := make(chan struct{}, 1)
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10}, e)
+	if err := tb.FillWithOptions(context.Background(), "tick", nil, WithInterval(time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tb.Shutdown(ctx, nil); err != nil {
+		t.Fatal("shutdown did not finish", err)
+	}
+	runs := len(e.executed())
+	time.Sleep(50 * time.Millisecond)
+	if n := len(e.executed()); n != runs || n > 2 {
+		t.Fatal("the recurring task kept running after shutdown", n)
+	}
+}
+
+func TestShutdownHandsOffWaitingTasks(t *testing.T) {
+	e := &funcExecutor{execute: sleeping(20 * time.Millisecond)}
+	tb := NewTaskBucket(&BucketConfig{
+		LifeSpan:   time.Second,
+		RunAfter:   time.Hour,
+		MaxBucket:  2,
+		MaxPending: 1,
+	}, e)
+	ctx := context.Background()
+	tb.Fill(ctx, ImmidiateTask, "running", nil)
+	tb.Fill(ctx, TimeBombTask, "bomb", nil)
+	tb.Fill(ctx, ImmidiateTask, "pending", nil)
+	time.Sleep(5 * time.Millisecond)
+	handed := make(map[string]bool)
+	if err := tb.Shutdown(ctx, func(st *TaskStatus, data interface{}) {
+		handed[st.ID] = true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(handed) != 2 || !handed["bomb"] || !handed["pending"] {
+		t.Fatal("unexpected handoff", handed)
+	}
+	if runs := e.executed(); len(runs) != 1 || runs[0] != "running" {
+		t.Fatal("unexpected runs", runs)
+	}
+	if err := tb.Fill(ctx, ImmidiateTask, "late", nil); err != ErrBucketClosed {
+		t.Fatal("expected ErrBucketClosed, got", err)
+	}
+}
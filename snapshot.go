@@ -0,0 +1,172 @@
+package gobucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+//SnapshotVersion is the version of the snapshot format written by Snapshot
+const SnapshotVersion = 1
+
+//snapshot is the serialized bucket, a newer version may only add fields
+type snapshot struct {
+	Version int             `json:"version"`
+	Taken   time.Time       `json:"taken"`
+	Tasks   []*snapshotTask `json:"tasks"`
+}
+
+//snapshotTask is a live task, the delays are relative to the snapshot time
+type snapshotTask struct {
+	ID                string            `json:"id"`
+	Type              TaskType          `json:"type"`
+	Data              json.RawMessage   `json:"data,omitempty"`
+	Queued            bool              `json:"queued,omitempty"` //fire time not known yet, i.e: in the pending queue
+	RemainingDelay    time.Duration     `json:"remaining_delay"`
+	RemainingLifeSpan *time.Duration    `json:"remaining_life_span,omitempty"` //nil means counted from the fire time
+	LifeSpan          time.Duration     `json:"life_span"`
+	RunAfter          time.Duration     `json:"run_after"`
+	Attempts          int               `json:"attempts"`
+	Priority          int               `json:"priority,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Partition         string            `json:"partition,omitempty"`
+	Enqueued          time.Time         `json:"enqueued"` //fill time, keeps the fill order on restore
+}
+
+//Snapshot writes the live tasks of the bucket as versioned JSON,
+//recurring, dependent and pipeline tasks are not included
+//args:
+//	w: snapshot destination
+//returns:
+//	error when a payload is not JSON serializable or the write failed
+func (tb *taskBucketImpl) Snapshot(w io.Writer) error {
+	now := time.Now()
+	s := &snapshot{
+		Version: SnapshotVersion,
+		Taken:   now,
+	}
+	tb.mux.Lock()
+	ts := make([]task, 0, len(tb.tasks)+tb.pending.Len())
+	for _, t := range tb.tasks {
+		ts = append(ts, t)
+	}
+	for _, p := range tb.pending.items {
+		ts = append(ts, p.task)
+	}
+	tb.mux.Unlock()
+	for _, t := range ts {
+		st, err := t.snapshot(now)
+		if err != nil {
+			return err
+		}
+		if st != nil {
+			s.Tasks = append(s.Tasks, st)
+		}
+	}
+	sortByEnqueued(s.Tasks)
+	return json.NewEncoder(w).Encode(s)
+}
+
+//Restore fills the tasks of a snapshot, the remaining delay and life span
+//are counted from now. The tasks which can not be filled are reported in the error
+//args:
+//	r: snapshot source
+//returns:
+//	error when the snapshot is invalid or some tasks are not restored
+func (tb *taskBucketImpl) Restore(r io.Reader) error {
+	var s snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return fmt.Errorf("unable to decode snapshot, err=%s", err.Error())
+	}
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+	sortByEnqueued(s.Tasks)
+	now := time.Now()
+	var failed []string
+	for _, st := range s.Tasks {
+		var data interface{}
+		var err error
+		if len(st.Data) > 0 {
+			data, err = tb.decode(st.Data)
+		}
+		if err == nil {
+			err = tb.fill(context.Background(), st.ID, data, nil, st.options(now))
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", st.ID, err.Error()))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to restore %d task(s), %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+//sortByEnqueued sorts the snapshot tasks in fill order
+func sortByEnqueued(sts []*snapshotTask) {
+	sort.SliceStable(sts, func(i, j int) bool {
+		return sts[i].Enqueued.Before(sts[j].Enqueued)
+	})
+}
+
+//options gets the fill options of the snapshot task
+func (st *snapshotTask) options(now time.Time) []TaskOption {
+	opts := []TaskOption{
+		WithTaskType(st.Type),
+		WithLifeSpan(st.LifeSpan),
+		WithRunAfter(st.RunAfter),
+		WithPriority(st.Priority),
+		WithLabels(st.Labels),
+		WithPartitionKey(st.Partition),
+	}
+	if st.Queued {
+		return opts
+	}
+	var deadline time.Time
+	if st.RemainingLifeSpan != nil {
+		deadline = now.Add(*st.RemainingLifeSpan)
+	}
+	return append(opts, withFireTime(now.Add(st.RemainingDelay), deadline))
+}
+
+//snapshot gets the serialized task, nil when the task can not be serialized
+func (t *taskImpl) snapshot(now time.Time) (*snapshotTask, error) {
+	if t.schedule != nil || len(t.deps) > 0 || t.forward != nil {
+		return nil, nil
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	raw, err := json.Marshal(t.data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to snapshot task with id=%s, err=%s", t.id, err.Error())
+	}
+	st := &snapshotTask{
+		ID:        t.id,
+		Type:      t.taskType,
+		Data:      raw,
+		LifeSpan:  t.lifeSpan,
+		RunAfter:  t.runAfter,
+		Attempts:  t.attempts,
+		Priority:  t.priority,
+		Labels:    t.labels,
+		Partition: t.partKey,
+		Enqueued:  t.enqueued,
+	}
+	if t.fireAt.IsZero() {
+		st.Queued = true
+		return st, nil
+	}
+	if d := t.fireAt.Sub(now); d > 0 {
+		st.RemainingDelay = d
+	}
+	if !t.deadline.IsZero() {
+		d := t.deadline.Sub(now)
+		st.RemainingLifeSpan = &d
+	}
+	return st, nil
+}
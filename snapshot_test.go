@@ -0,0 +1,102 @@
+package gobucket
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+//snapshotBucket holds a time bomb, a running task and three queued tasks
+//filled out of id order
+func snapshotBucket(t *testing.T, release chan struct{}) TaskBucket {
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		<-release
+		return nil
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Hour, MaxBucket: 2, MaxPending: 10}, e)
+	ctx := context.Background()
+	if err := tb.FillWithOptions(ctx, "bomb", map[string]interface{}{"n": 1.0}, WithRunAfter(time.Hour), WithLifeSpan(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"x", "z1", "a2", "m3"} {
+		if err := tb.Fill(ctx, ImmidiateTask, id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tb
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	src := snapshotBucket(t, release)
+	defer src.(*taskBucketImpl).sched.close()
+	time.Sleep(20 * time.Millisecond)
+	bomb, err := src.Status("bomb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	running, err := src.Status("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	gate := make(chan struct{})
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		if id == "x" {
+			<-gate
+		}
+		return nil
+	}
+	dst := NewTaskBucket(&BucketConfig{LifeSpan: time.Minute, MaxBucket: 2, MaxPending: 10}, e)
+	defer dst.(*taskBucketImpl).sched.close()
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	after, err := dst.Status("bomb")
+	if err != nil {
+		t.Fatal("the time bomb was not restored", err)
+	}
+	if !closeTo(after.FireAt, bomb.FireAt) {
+		t.Fatal("the remaining delay was not kept", bomb.FireAt, after.FireAt)
+	}
+	restored, err := dst.Status("x")
+	if err != nil {
+		t.Fatal("the running task was not restored", err)
+	}
+	if !closeTo(restored.Deadline, running.Deadline) {
+		t.Fatal("the remaining life span was not kept", running.Deadline, restored.Deadline)
+	}
+	if n := dst.Stats().Pending; n != 3 {
+		t.Fatal("the queued tasks were not restored to the queue", n)
+	}
+	close(gate)
+	deadline := time.Now().Add(time.Second)
+	for len(e.executed()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, want := e.executed(), []string{"x", "z1", "a2", "m3"}; !reflect.DeepEqual(got, want) {
+		t.Fatal("the fill order was not kept", got)
+	}
+}
+
+func TestRestoreRejectsUnknownVersion(t *testing.T) {
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 1}, &funcExecutor{})
+	defer tb.(*taskBucketImpl).sched.close()
+	for _, raw := range []string{`{"version":0,"tasks":[]}`, `{"version":2,"tasks":[]}`} {
+		err := tb.Restore(strings.NewReader(raw))
+		if err == nil || !strings.Contains(err.Error(), "unsupported snapshot version") {
+			t.Fatal("expected a version error for", raw, err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package gobucket
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//TaskState defines the state of the task
+type TaskState string
+
+const (
+	StateScheduled TaskState = "scheduled" //waiting for its fire time
+	StateWaiting   TaskState = "waiting"   //waiting for a slot, a worker or the parent tasks
+	StateRunning   TaskState = "running"   //OnExecute is running
+	StateFinishing TaskState = "finishing" //OnFinish or OnExecuteError is running
+	StateExhausted TaskState = "exhausted" //life span or queue timeout reached
+	StateFinished  TaskState = "finished"  //OnExecute succeeded
+	StateFailed    TaskState = "failed"    //OnExecute returned error
+	StateDrained   TaskState = "drained"   //removed by Drain
+	StateRescued   TaskState = "rescued"   //removed by Rescue
+)
+
+//TaskStatus describes a task inside the bucket
+type TaskStatus struct {
+	ID       string
+	State    TaskState
+	Type     TaskType
+	Enqueued time.Time //fill time
+	FireAt   time.Time //scheduled fire time, zero when not yet known
+	Deadline time.Time //life span deadline, zero when not yet known
+	Attempts int
+	Runs     int
+	Priority int
+	Labels   map[string]string
+}
+
+//RescuedTask is a task stopped by Rescue, the status tells how far it had progressed
+type RescuedTask struct {
+	TaskStatus
+	Data interface{}
+}
+
+//TaskFilter selects the tasks to be listed, zero value field matches any
+type TaskFilter struct {
+	State  TaskState
+	Type   TaskType
+	Labels Selector //every label should match
+	Prefix string   //task identity prefix
+}
+
+func (f *TaskFilter) match(s *TaskStatus) bool {
+	if f == nil {
+		return true
+	}
+	if f.State != "" && f.State != s.State {
+		return false
+	}
+	if f.Type != "" && f.Type != s.Type {
+		return false
+	}
+	if f.Prefix != "" && !strings.HasPrefix(s.ID, f.Prefix) {
+		return false
+	}
+	return f.Labels.Matches(s.Labels)
+}
+
+//BucketStats summarizes the bucket
+type BucketStats struct {
+	Tasks         int //tasks held by the bucket, excluding the pending queue
+	Pending       int //tasks waiting in the pending queue
+	Scheduled     int
+	Waiting       int //including the pending queue
+	Running       int
+	Finishing     int
+	Exhausted     int
+	MaxBucket     int
+	MaxPending    int
+	MaxConcurrent int
+	Paused        bool
+}
+
+//Status gets the status of a task in the bucket
+//args:
+//	id: task identity
+//returns:
+//	task status, error when the task is not found
+func (tb *taskBucketImpl) Status(id string) (*TaskStatus, error) {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	if t, ok := tb.tasks[id]; ok {
+		return t.status(), nil
+	}
+	if p, ok := tb.pending.get(id); ok {
+		return pendingStatus(p), nil
+	}
+	return nil, fmt.Errorf("task with id %s is not found", id)
+}
+
+//List gets the status of the tasks matching the filter, nil filter lists all
+func (tb *taskBucketImpl) List(filter *TaskFilter) []*TaskStatus {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	var ss []*TaskStatus
+	for _, t := range tb.tasks {
+		if s := t.status(); filter.match(s) {
+			ss = append(ss, s)
+		}
+	}
+	for _, p := range tb.pending.items {
+		if s := pendingStatus(p); filter.match(s) {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+//Stats counts the tasks by state
+func (tb *taskBucketImpl) Stats() BucketStats {
+	tb.mux.Lock()
+	defer tb.mux.Unlock()
+	cfg := tb.conf()
+	st := BucketStats{
+		Tasks:         len(tb.tasks),
+		Pending:       tb.pending.Len(),
+		Waiting:       tb.pending.Len(),
+		MaxBucket:     cfg.MaxBucket,
+		MaxPending:    cfg.MaxPending,
+		MaxConcurrent: cfg.MaxConcurrent,
+		Paused:        tb.paused != nil,
+	}
+	for _, t := range tb.tasks {
+		switch t.status().State {
+		case StateScheduled:
+			st.Scheduled++
+		case StateWaiting:
+			st.Waiting++
+		case StateRunning:
+			st.Running++
+		case StateFinishing:
+			st.Finishing++
+		case StateExhausted:
+			st.Exhausted++
+		}
+	}
+	return st
+}
+
+func pendingStatus(p *pendingTask) *TaskStatus {
+	s := p.task.status()
+	s.State = StateWaiting
+	return s
+}
+
+//status gets the current status of the task
+func (t *taskImpl) status() *TaskStatus {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	s := &TaskStatus{
+		ID:       t.id,
+		State:    t.state,
+		Type:     t.taskType,
+		Enqueued: t.enqueued,
+		FireAt:   t.fireAt,
+		Deadline: t.deadline,
+		Attempts: t.attempts,
+		Runs:     t.runs,
+		Priority: t.priority,
+	}
+	if len(t.labels) > 0 {
+		s.Labels = make(map[string]string, len(t.labels))
+		for k, v := range t.labels {
+			s.Labels[k] = v
+		}
+	}
+	return s
+}
+
+//advance moves a task waiting for a worker to the given state
+func (t *taskImpl) advance(s TaskState) {
+	t.mux.Lock()
+	if t.state == StateWaiting {
+		t.state = s
+	}
+	t.mux.Unlock()
+}
@@ -0,0 +1,378 @@
+package gobucket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	opPut = "put"
+	opDel = "del"
+
+	compactMin = 1024 //dead records before the file store compacts itself
+)
+
+//Store persists the tasks so they survive a process crash, the task is saved
+//on fill and deleted once it leaves the bucket
+type Store interface {
+	Save(t *StoredTask) error
+	Delete(id string) error
+	Load() ([]*StoredTask, error) //unfinished tasks
+}
+
+//StoredTask is the persisted form of a task, a zero fire time means the task
+//was waiting in the pending queue and its timer starts again on restore
+type StoredTask struct {
+	ID        string            `json:"id"`
+	Type      TaskType          `json:"type"`
+	Data      json.RawMessage   `json:"data,omitempty"`
+	Enqueued  time.Time         `json:"enqueued"`
+	FireAt    time.Time         `json:"fire_at,omitempty"`
+	Deadline  time.Time         `json:"deadline,omitempty"`
+	LifeSpan  time.Duration     `json:"life_span"`
+	RunAfter  time.Duration     `json:"run_after"`
+	Priority  int               `json:"priority,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Partition string            `json:"partition,omitempty"`
+}
+
+//newStoredTask gets the persisted form of a task being filled,
+//nil when the task can not be persisted
+func newStoredTask(id string, data interface{}, o *taskOptions, queued bool) (*StoredTask, error) {
+	//recurring, dependent and pipeline tasks are not persisted
+	if o.schedule != nil || len(o.deps) > 0 || o.forward != nil {
+		return nil, nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to persist task with id=%s, err=%s", id, err.Error())
+	}
+	now := time.Now()
+	st := &StoredTask{
+		ID:        id,
+		Type:      o.taskType,
+		Data:      raw,
+		Enqueued:  now,
+		LifeSpan:  o.lifeSpan,
+		RunAfter:  o.runAfter,
+		Priority:  o.priority,
+		Labels:    o.labels,
+		Partition: o.partKey,
+	}
+	switch {
+	case !o.fireAt.IsZero():
+		st.FireAt, st.Deadline = o.fireAt, o.deadline
+	case queued:
+	case !o.runAt.IsZero():
+		st.FireAt = o.runAt
+	case o.taskType == TimeBombTask:
+		st.FireAt, st.Deadline = now.Add(o.runAfter), now.Add(o.lifeSpan)
+	case o.taskType == DebounceTask:
+		st.FireAt = now.Add(o.runAfter)
+	default:
+		st.FireAt, st.Deadline = now, now.Add(o.lifeSpan)
+	}
+	return st, nil
+}
+
+//storeOp is a store write queued with the lock held, flush applies
+//the writes in queue order without holding the lock
+type storeOp struct {
+	task *StoredTask //saved when set, otherwise id is deleted
+	id   string
+	err  error
+}
+
+//persist queues the save of the task being filled, must be called with lock held,
+//nil when the task is not persisted
+func (tb *taskBucketImpl) persist(id string, data interface{}, o *taskOptions, queued bool) (*storeOp, error) {
+	if tb.conf().Store == nil {
+		return nil, nil
+	}
+	st, err := newStoredTask(id, data, o, queued)
+	if err != nil || st == nil {
+		return nil, err
+	}
+	op := &storeOp{task: st, id: id}
+	tb.writes = append(tb.writes, op)
+	return op, nil
+}
+
+//unpersist queues the deletion of the ended task, must be called with lock held
+func (tb *taskBucketImpl) unpersist(id string) {
+	if tb.conf().Store == nil {
+		return
+	}
+	tb.writes = append(tb.writes, &storeOp{id: id})
+}
+
+//flush applies the queued store writes, must be called without lock,
+//returns the error of op which is written once flush returns
+func (tb *taskBucketImpl) flush(op *storeOp) error {
+	store := tb.conf().Store
+	if store == nil {
+		return nil
+	}
+	tb.flushMux.Lock()
+	defer tb.flushMux.Unlock()
+	tb.mux.Lock()
+	ops := tb.writes
+	tb.writes = nil
+	tb.mux.Unlock()
+	for _, w := range ops {
+		if w.task != nil {
+			w.err = store.Save(w.task)
+		} else {
+			w.err = store.Delete(w.id)
+		}
+		if w.err != nil {
+			tb.log("task_bucket: unable to write task=", w.id, "to store, err=", w.err.Error())
+		}
+	}
+	if op == nil {
+		return nil
+	}
+	return op.err
+}
+
+//stored waits until the filled task is saved, the task is drained
+//when it can not be saved
+func (tb *taskBucketImpl) stored(id string, op *storeOp) error {
+	if op == nil {
+		return nil
+	}
+	if err := tb.flush(op); err != nil {
+		tb.Drain(context.Background(), id)
+		return err
+	}
+	return nil
+}
+
+//restore re-arms the unfinished tasks of the store, keeping their original
+//fire time and deadline
+func (tb *taskBucketImpl) restore() {
+	store := tb.conf().Store
+	if store == nil {
+		return
+	}
+	sts, err := store.Load()
+	if err != nil {
+		log.Println("task_bucket: unable to load the tasks from store, err=", err)
+		return
+	}
+	sort.Slice(sts, func(i, j int) bool {
+		return sts[i].Enqueued.Before(sts[j].Enqueued)
+	})
+	for _, st := range sts {
+		var data interface{}
+		if len(st.Data) > 0 {
+			if data, err = tb.decode(st.Data); err != nil {
+				log.Println("task_bucket: unable to decode stored task=", st.ID, "err=", err)
+				continue
+			}
+		}
+		opts := []TaskOption{
+			WithTaskType(st.Type),
+			WithLifeSpan(st.LifeSpan),
+			WithRunAfter(st.RunAfter),
+			WithPriority(st.Priority),
+			WithLabels(st.Labels),
+			WithPartitionKey(st.Partition),
+			withFireTime(st.FireAt, st.Deadline),
+		}
+		if err := tb.fill(context.Background(), st.ID, data, nil, opts); err != nil {
+			log.Println("task_bucket: unable to restore task=", st.ID, "err=", err)
+			continue
+		}
+		tb.log("task_bucket: restored task=", st.ID, "fire at", st.FireAt.String())
+	}
+}
+
+//FileStore is an append only log of the tasks, it is compacted on Load
+//and once the deleted records outnumber the live ones
+type FileStore struct {
+	mux     sync.Mutex
+	path    string
+	file    *os.File
+	live    map[string]struct{}
+	records int
+}
+
+type storeRecord struct {
+	Op   string      `json:"op"`
+	ID   string      `json:"id,omitempty"`
+	Task *StoredTask `json:"task,omitempty"`
+}
+
+//NewFileStore opens the task log file, the file is created when not exists
+//args:
+//	path: file path of the log
+//returns:
+//	file store, error when the file can not be opened
+func NewFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		path: path,
+		file: f,
+		live: make(map[string]struct{}),
+	}, nil
+}
+
+//Save appends the task to the log
+func (fs *FileStore) Save(t *StoredTask) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	if err := fs.append(&storeRecord{Op: opPut, Task: t}); err != nil {
+		return err
+	}
+	fs.live[t.ID] = struct{}{}
+	return nil
+}
+
+//Delete appends the deletion of the task to the log
+func (fs *FileStore) Delete(id string) error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	if _, ok := fs.live[id]; !ok {
+		return nil
+	}
+	if err := fs.append(&storeRecord{Op: opDel, ID: id}); err != nil {
+		return err
+	}
+	delete(fs.live, id)
+	if fs.records > 2*len(fs.live)+compactMin {
+		return fs.compact()
+	}
+	return nil
+}
+
+//Load replays the log and compacts it
+func (fs *FileStore) Load() ([]*StoredTask, error) {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	tasks, err := fs.replay()
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.rewrite(tasks); err != nil {
+		return nil, err
+	}
+	sts := make([]*StoredTask, 0, len(tasks))
+	for _, t := range tasks {
+		sts = append(sts, t)
+	}
+	return sts, nil
+}
+
+//Close closes the log file
+func (fs *FileStore) Close() error {
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	return fs.file.Close()
+}
+
+//append writes the record and syncs the file, must be called with lock held
+func (fs *FileStore) append(r *storeRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := fs.file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	fs.records++
+	return fs.file.Sync()
+}
+
+//replay reads the live tasks from the log, must be called with lock held
+func (fs *FileStore) replay() (map[string]*StoredTask, error) {
+	raw, err := os.ReadFile(fs.path)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make(map[string]*StoredTask)
+	lines := bytes.Split(raw, []byte("\n"))
+	for i, l := range lines {
+		if len(l) == 0 {
+			continue
+		}
+		var r storeRecord
+		if err := json.Unmarshal(l, &r); err != nil {
+			if i == len(lines)-1 {
+				//torn write of the last record while crashing
+				break
+			}
+			return nil, fmt.Errorf("corrupted store %s at line %d, err=%s", fs.path, i+1, err.Error())
+		}
+		switch r.Op {
+		case opPut:
+			tasks[r.Task.ID] = r.Task
+		case opDel:
+			delete(tasks, r.ID)
+		}
+	}
+	return tasks, nil
+}
+
+//compact rewrites the log with the live tasks only, must be called with lock held
+func (fs *FileStore) compact() error {
+	tasks, err := fs.replay()
+	if err != nil {
+		return err
+	}
+	return fs.rewrite(tasks)
+}
+
+//rewrite replaces the log atomically, must be called with lock held
+func (fs *FileStore) rewrite(tasks map[string]*StoredTask) error {
+	tmp := fs.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, t := range tasks {
+		b, err := json.Marshal(&storeRecord{Op: opPut, Task: t})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(b, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, fs.path); err != nil {
+		return err
+	}
+	fs.file.Close()
+	fs.file, err = os.OpenFile(fs.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	fs.records = len(tasks)
+	fs.live = make(map[string]struct{}, len(tasks))
+	for id := range tasks {
+		fs.live[id] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package gobucket
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openStore(t *testing.T, path string) *FileStore {
+	fs, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fs.Close() })
+	return fs
+}
+
+func loadIDs(t *testing.T, fs *FileStore) map[string]*StoredTask {
+	sts, err := fs.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]*StoredTask, len(sts))
+	for _, st := range sts {
+		ids[st.ID] = st
+	}
+	return ids
+}
+
+func TestFileStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	fs := openStore(t, path)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := fs.Save(&StoredTask{ID: id, Type: TimeBombTask, Data: []byte(`{"n":1}`)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Save(&StoredTask{ID: "c", Data: []byte(`{"n":2}`)}); err != nil {
+		t.Fatal(err)
+	}
+	fs.Close()
+	ids := loadIDs(t, openStore(t, path))
+	if len(ids) != 2 || ids["a"] == nil || ids["c"] == nil {
+		t.Fatal("unexpected tasks", ids)
+	}
+	if string(ids["c"].Data) != `{"n":2}` || ids["a"].Type != TimeBombTask {
+		t.Fatal("the last save was not kept", string(ids["c"].Data), ids["a"].Type)
+	}
+}
+
+func TestFileStoreTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	fs := openStore(t, path)
+	if err := fs.Save(&StoredTask{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	fs.Close()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"put","task":{"id":"b"`)
+	f.Close()
+	ids := loadIDs(t, openStore(t, path))
+	if len(ids) != 1 || ids["a"] == nil {
+		t.Fatal("unexpected tasks", ids)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte(`"b"`)) {
+		t.Fatal("the torn record was not dropped by Load")
+	}
+}
+
+func TestFileStoreCorruptedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	if err := os.WriteFile(path, []byte("garbage\n{\"op\":\"put\",\"task\":{\"id\":\"a\"}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openStore(t, path).Load(); err == nil {
+		t.Fatal("expected an error for a corrupted record")
+	}
+}
+
+func TestFileStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	fs := openStore(t, path)
+	if err := fs.Save(&StoredTask{ID: "keep"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < compactMin; i++ {
+		id := strconv.Itoa(i)
+		if err := fs.Save(&StoredTask{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(raw, []byte("\n")); lines > compactMin+3 {
+		t.Fatal("the log was not compacted, lines", lines)
+	}
+	fs.Close()
+	ids := loadIDs(t, openStore(t, path))
+	if len(ids) != 1 || ids["keep"] == nil {
+		t.Fatal("unexpected tasks", ids)
+	}
+}
+
+func TestStoreRestoreKeepsFireTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	cfg := &BucketConfig{
+		LifeSpan:  2 * time.Hour,
+		RunAfter:  time.Hour,
+		MaxBucket: 10,
+		Store:     openStore(t, path),
+	}
+	tb := NewTaskBucket(cfg, &funcExecutor{})
+	ctx := context.Background()
+	if err := tb.Fill(ctx, TimeBombTask, "bomb", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tb.Fill(ctx, ImmidiateTask, "done", nil); err != nil {
+		t.Fatal(err)
+	}
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	for {
+		if _, err := tb.Status("done"); err != nil {
+			break
+		}
+		select {
+		case <-wctx.Done():
+			t.Fatal("the immediate task did not finish")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	before, err := tb.Status("bomb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//simulate a crash, the bucket is abandoned without shutdown
+	tb.(*taskBucketImpl).sched.close()
+	cfg.Store.(*FileStore).Close()
+	time.Sleep(50 * time.Millisecond)
+
+	e := &funcExecutor{}
+	cfg.Store = openStore(t, path)
+	restored := NewTaskBucket(cfg, e)
+	defer restored.(*taskBucketImpl).sched.close()
+	after, err := restored.Status("bomb")
+	if err != nil {
+		t.Fatal("the time bomb was not restored", err)
+	}
+	//the stored times are taken while filling, a moment before the task is armed
+	if !closeTo(after.FireAt, before.FireAt) || !closeTo(after.Deadline, before.Deadline) {
+		t.Fatal("fire time moved", before.FireAt, after.FireAt, before.Deadline, after.Deadline)
+	}
+	if _, err := restored.Status("done"); err == nil {
+		t.Fatal("the finished task was restored")
+	}
+}
+
+func closeTo(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -10*time.Millisecond && d < 10*time.Millisecond
+}
+
+//blockingStore blocks Delete until release is closed
+type blockingStore struct {
+	Store
+	deleting chan struct{}
+	release  chan struct{}
+}
+
+func (s *blockingStore) Delete(id string) error {
+	s.deleting <- struct{}{}
+	<-s.release
+	return s.Store.Delete(id)
+}
+
+func TestStoreWritesDoNotHoldBucketLock(t *testing.T) {
+	store := &blockingStore{
+		Store:    openStore(t, filepath.Join(t.TempDir(), "tasks.wal")),
+		deleting: make(chan struct{}, 1),
+		release:  make(chan struct{}),
+	}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 10, Store: store}, &funcExecutor{})
+	if err := tb.Fill(context.Background(), ImmidiateTask, "a", nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-store.deleting:
+	case <-time.After(time.Second):
+		t.Fatal("the task was not deleted from the store")
+	}
+	done := make(chan struct{})
+	go func() {
+		tb.Stats()
+		tb.List(nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the bucket is locked while the store is written")
+	}
+	close(store.release)
+}
+
+//failingStore can not save any task
+type failingStore struct {
+	Store
+}
+
+func (failingStore) Save(t *StoredTask) error {
+	return os.ErrPermission
+}
+
+func TestStoreSaveErrorRejectsFill(t *testing.T) {
+	e := &funcExecutor{}
+	store := failingStore{openStore(t, filepath.Join(t.TempDir(), "tasks.wal"))}
+	tb := NewTaskBucket(&BucketConfig{LifeSpan: time.Second, MaxBucket: 1, MaxPending: 1, Store: store}, e)
+	ctx := context.Background()
+	for _, id := range []string{"a", "b"} {
+		if err := tb.Fill(ctx, ImmidiateTask, id, nil); err != os.ErrPermission {
+			t.Fatal("expected the store error, got", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := len(e.executed()); n != 0 || tb.length() != 0 {
+		t.Fatal("the unsaved task was kept", n, tb.length())
+	}
+}
+
+func TestShutdownKeepsStoredTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	release := make(chan struct{})
+	e := &funcExecutor{}
+	e.execute = func(ctx context.Context, id string, data interface{}) error {
+		if id == "running" {
+			<-release
+		}
+		return nil
+	}
+	cfg := &BucketConfig{
+		LifeSpan:   time.Hour,
+		RunAfter:   time.Hour,
+		MaxBucket:  2,
+		MaxPending: 1,
+		Store:      openStore(t, path),
+	}
+	tb := NewTaskBucket(cfg, e)
+	ctx := context.Background()
+	tb.Fill(ctx, ImmidiateTask, "running", nil)
+	tb.Fill(ctx, ImmidiateTask, "finished", nil)
+	tb.Fill(ctx, TimeBombTask, "pending", nil)
+	time.Sleep(20 * time.Millisecond)
+	tb.Fill(ctx, TimeBombTask, "bomb", nil)
+	sctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := tb.Shutdown(sctx, nil); err == nil {
+		t.Fatal("expected the running task to time out")
+	}
+	close(release)
+	cfg.Store.(*FileStore).Close()
+	ids := loadIDs(t, openStore(t, path))
+	if len(ids) != 3 || ids["running"] == nil || ids["pending"] == nil || ids["bomb"] == nil {
+		t.Fatal("unexpected stored tasks", ids)
+	}
+}
+
+func TestShutdownHandoffDeletesStoredTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.wal")
+	cfg := &BucketConfig{
+		LifeSpan:  time.Hour,
+		RunAfter:  time.Hour,
+		MaxBucket: 2,
+		Store:     openStore(t, path),
+	}
+	tb := NewTaskBucket(cfg, &funcExecutor{})
+	ctx := context.Background()
+	tb.Fill(ctx, TimeBombTask, "bomb", nil)
+	var handed []string
+	err := tb.Shutdown(ctx, func(st *TaskStatus, data interface{}) {
+		handed = append(handed, st.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Store.(*FileStore).Close()
+	if ids := loadIDs(t, openStore(t, path)); len(handed) != 1 || len(ids) != 0 {
+		t.Fatal("the handed off task was kept", handed, ids)
+	}
+}
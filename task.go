@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"runtime/debug"
+	"sync"
 	"time"
 )
 
@@ -20,36 +22,122 @@ var (
 const (
 	ImmidiateTask TaskType = "ImmidiateTask"
 	TimeBombTask  TaskType = "TimeBomb"
+	RecurringTask TaskType = "Recurring"
+	DebounceTask  TaskType = "Debounce"
+)
+
+//runResult tells how a single run of the task ended
+type runResult int
+
+const (
+	runDone    runResult = iota //finished, failed or exhausted
+	runQuit                     //drained from outside
+	runRescued                  //rescued by panic signal
 )
 
 type TaskType string
 
+//PastPolicy decides what to do with a task scheduled at a time already passed
+type PastPolicy int
+
+const (
+	RunPastImmediately PastPolicy = iota //run the task right away
+	RejectPast                           //reject the task on fill
+)
+
 type BucketConfig struct {
-	LifeSpan  time.Duration
-	RunAfter  time.Duration
-	MaxBucket int
-	Verbose   bool
+	LifeSpan   time.Duration
+	RunAfter   time.Duration
+	MaxBucket  int
+	Verbose    bool
+	PastPolicy PastPolicy
+	Retry      *RetryPolicy
+
+	MaxConcurrent int     //max OnExecute running at once, 0 means no limit
+	RateLimit     float64 //OnExecute starts per second, 0 means no limit
+	RateBurst     int     //OnExecute starts allowed at once, default 1
+	Dispatchers   int     //goroutines firing the delayed tasks, default 4
+
+	PauseFreezesLifeSpan bool //the life span of a task waiting for Resume does not count the pause, also between retries
+
+	ResultRetention time.Duration //how long the result is kept after the task ended, 0 means not kept
+
+	Store Store //persists the tasks, the unfinished tasks are restored by NewTaskBucket
+
+	DedupeWindow time.Duration //how long a succeeded task id is remembered, 0 means no dedupe
+	DedupeSize   int           //max remembered task ids, default 10000
+	DedupePolicy DedupePolicy  //what Fill does with a remembered task id
+
+	EventBuffer int        //buffered events per subscriber, default 256
+	EventDrop   DropPolicy //what to drop when a subscriber is slow
+
+	MaxPending   int           //waiting tasks when MaxBucket is reached, 0 means reject right away
+	QueueOrder   QueueOrder    //order of the waiting tasks
+	QueueTimeout time.Duration //max wait in the queue, 0 means no timeout
 }
 
 type task interface {
-	run(ctx context.Context, e Executor)
+	start()
 	drain(ctx context.Context, quitting bool) error
-	rescue(ctx context.Context) error
+	rescue(ctx context.Context) bool
+	outcome() *TaskResult
+	status() *TaskStatus
+	payload() interface{}
+	stop(state TaskState) bool
+	snapshot(now time.Time) (*snapshotTask, error)
+	debounce(data interface{}, reducer Reducer) bool
+	partition() string
+	grant()
+	identity() string
+	parents() []dependency
+	watchParents()
+	quit()
 }
 
 type baseTask struct {
 	verbose     bool
 	tb          TaskBucket
 	signalQuit  chan bool
-	isQuit      bool
+	quitOnce    sync.Once
 	taskType    TaskType
 	signalPanic chan bool
+	ended       chan struct{}
+	endOnce     sync.Once
 }
 
 type taskImpl struct {
 	*bucket
 	*baseTask
+	mux      sync.Mutex
+	ctx      context.Context
+	e        Executor
+	entry    *timerEntry
+	running  bool
+	state    TaskState
+	enqueued time.Time
+	started  time.Time
+	fireAt   time.Time
+	deadline time.Time
 	runAfter time.Duration
+	priority int
+	labels   map[string]string
+	schedule Schedule
+	maxRuns  int
+	endTime  time.Time
+	runAt    time.Time
+	runs     int
+	retry    *RetryPolicy
+	attempts int
+	maxWait  time.Duration
+	partKey  string
+	turn     chan struct{} //closed once the task is first in its partition
+
+	deps      []dependency
+	depPolicy DependencyPolicy
+	waiting   int
+
+	result  interface{}
+	forward forwardFunc
 }
 
 type bucket struct {
@@ -60,54 +148,276 @@ type bucket struct {
 	data         interface{}
 }
 
-func newTask(taskType TaskType, id string, cfg *BucketConfig, data interface{}, tb TaskBucket) task {
+func newTask(ctx context.Context, id string, data interface{}, opts *taskOptions, verbose bool, tb TaskBucket, e Executor) task {
+	var turn chan struct{}
+	if opts.partKey != "" {
+		turn = make(chan struct{})
+	}
 	return &taskImpl{
 		bucket: &bucket{
 			id:       id,
-			lifeSpan: cfg.LifeSpan,
+			lifeSpan: opts.lifeSpan,
 			data:     data,
 		},
 		baseTask: &baseTask{
-			verbose:     cfg.Verbose,
+			verbose:     verbose,
 			tb:          tb,
 			signalQuit:  make(chan bool),
 			signalPanic: make(chan bool),
-			taskType:    taskType,
+			ended:       make(chan struct{}),
+			taskType:    opts.taskType,
 		},
-		runAfter: cfg.RunAfter,
+		runAfter:  opts.runAfter,
+		priority:  opts.priority,
+		labels:    opts.labels,
+		schedule:  opts.schedule,
+		maxRuns:   opts.maxRuns,
+		endTime:   opts.endTime,
+		runAt:     opts.runAt,
+		retry:     opts.retry,
+		maxWait:   opts.maxWait,
+		partKey:   opts.partKey,
+		turn:      turn,
+		deps:      opts.deps,
+		depPolicy: opts.depPolicy,
+		forward:   opts.forward,
+		state:     StateScheduled,
+		enqueued:  time.Now(),
+		ctx:       ctx,
+		e:         e,
+		fireAt:    opts.fireAt,
+		deadline:  opts.deadline,
 	}
 }
 
-func (t *taskImpl) run(ctx context.Context, e Executor) {
-	rctx, cancel := context.WithTimeout(ctx, t.lifeSpan)
-	defer cancel()
-	var shouldCleanup bool
-	finished := make(chan bool)
-	go func() {
-		if t.baseTask.taskType == TimeBombTask {
-			t.log(t.id, "wait for ", t.runAfter.Seconds(), " second")
-			time.Sleep(t.runAfter)
+//start arms the task, a delayed task waits in the bucket scheduler
+//without holding any goroutine until it fires
+func (t *taskImpl) start() {
+	if len(t.deps) > 0 {
+		//release the guard taken by watchParents
+		t.parentDone(true)
+		return
+	}
+	t.launch()
+}
+
+//launch arms the task once it is free to run
+func (t *taskImpl) launch() {
+	ctx := t.ctx
+	now := time.Now()
+	switch {
+	case t.taskType == RecurringTask:
+		if !t.next(now) {
+			t.drain(ctx, false)
+		}
+	case !t.fireAt.IsZero():
+		//restored task keeps its original fire time and deadline
+		t.log(t.id, "restored, wait until ", t.fireAt.String())
+		t.arm(t.fireAt, t.deadline)
+	case !t.runAt.IsZero():
+		t.log(t.id, "wait until ", t.runAt.String())
+		t.arm(t.runAt, time.Time{})
+	case t.taskType == TimeBombTask:
+		t.log(t.id, "wait for ", t.runAfter.Seconds(), " second")
+		t.arm(now.Add(t.runAfter), now.Add(t.lifeSpan))
+	case t.taskType == DebounceTask:
+		t.log(t.id, "debounce for ", t.runAfter.Seconds(), " second")
+		t.arm(t.debounceAt(now), time.Time{})
+	default:
+		t.mux.Lock()
+		t.fireAt = now
+		t.mux.Unlock()
+		go t.fire(now.Add(t.lifeSpan))
+	}
+}
+
+//arm schedules the task to fire at the given time, a zero deadline
+//means the life span is counted from the firing time
+func (t *taskImpl) arm(at, deadline time.Time) {
+	if !deadline.IsZero() && deadline.Before(at) {
+		at = deadline
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.isQuit() {
+		return
+	}
+	t.armLocked(at, deadline)
+}
+
+//armLocked arms the task, must be called with lock held
+func (t *taskImpl) armLocked(at, deadline time.Time) {
+	t.state = StateScheduled
+	t.fireAt = at
+	t.deadline = deadline
+	t.entry = t.tb.schedule(at, func() {
+		go t.fire(deadline)
+	})
+}
+
+//unschedule cancels the armed timer, false when the task is not waiting
+func (t *taskImpl) unschedule() bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.entry == nil {
+		return false
+	}
+	ok := t.tb.unschedule(t.entry)
+	t.entry = nil
+	return ok
+}
+
+//fire runs the task once it is due, then re-arms a recurring task
+//or drains the task from the bucket
+func (t *taskImpl) fire(deadline time.Time) {
+	t.mux.Lock()
+	t.entry = nil
+	if t.isQuit() {
+		t.mux.Unlock()
+		return
+	}
+	t.state = StateWaiting
+	t.mux.Unlock()
+	waited, ok := t.waitTurn()
+	if !ok {
+		return
+	}
+	//the life span runs from here, the time queued behind MaxConcurrent or
+	//the rate limit is added afterwards so it does not exhaust the task
+	if deadline.IsZero() {
+		deadline = time.Now().Add(t.lifeSpan)
+	} else {
+		deadline = deadline.Add(waited)
+	}
+	pctx := context.Background()
+	if !t.tb.conf().PauseFreezesLifeSpan {
+		//the life span keeps running while paused
+		var cancel context.CancelFunc
+		pctx, cancel = context.WithDeadline(pctx, deadline)
+		defer cancel()
+	}
+	frozen, err := t.tb.waitResume(pctx, t.signalQuit)
+	var res runResult
+	switch {
+	case err == context.DeadlineExceeded:
+		if !t.begin(deadline) {
+			return
 		}
-		if !t.baseTask.isQuit {
-			t.onExecuteErr = e.OnExecute(rctx, t.id, t.data)
+		ectx, cancel := withLifeSpan(t.ctx, deadline)
+		t.exhaust(ectx, t.e)
+		cancel()
+		res = runDone
+	case err != nil:
+		return
+	default:
+		acquired, err := t.tb.acquire(context.Background(), t.signalQuit)
+		if err != nil {
+			return
 		}
-		finished <- true
-		close(finished)
-	}()
+		deadline = deadline.Add(frozen + acquired)
+		if !t.begin(deadline) {
+			t.tb.release()
+			return
+		}
+		res = t.execute(t.ctx, t.e, deadline)
+	}
+	switch res {
+	case runQuit:
+		return
+	case runRescued:
+		t.drain(t.ctx, false)
+		return
+	}
+	if t.taskType == RecurringTask {
+		t.mux.Lock()
+		t.runs++
+		t.running = false
+		t.mux.Unlock()
+		if t.maxRuns > 0 && t.runs >= t.maxRuns {
+			t.log(t.id, "max runs reached")
+		} else if t.tb.isClosed() {
+			//Shutdown skipped the task while it was running
+			t.log(t.id, "bucket is shut down, no more runs")
+		} else if t.next(time.Now()) {
+			return
+		}
+	}
+	t.drain(t.ctx, false)
+}
+
+//begin marks the task as running until the deadline, false when the task quit
+func (t *taskImpl) begin(deadline time.Time) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.isQuit() {
+		return false
+	}
+	t.running = true
+	t.state = StateWaiting
+	t.deadline = deadline
+	return true
+}
+
+//next arms the recurring task for its next run, false when the schedule ended
+func (t *taskImpl) next(now time.Time) bool {
+	at := t.schedule.Next(now)
+	if t.runs == 0 && !t.runAt.IsZero() {
+		at = t.runAt
+	}
+	if at.IsZero() || (!t.endTime.IsZero() && at.After(t.endTime)) {
+		t.log(t.id, "recurring schedule ended after ", t.runs, " run(s)")
+		return false
+	}
+	t.log(t.id, "next run at ", at.String())
+	t.arm(at, time.Time{})
+	return true
+}
+
+//execute runs the executor once until the deadline, the worker slot
+//taken by fire is released by the first attempt
+func (t *taskImpl) execute(ctx context.Context, e Executor, deadline time.Time) runResult {
+	rctx, cancel := withLifeSpan(ctx, deadline)
+	defer cancel()
+	finished := make(chan bool, 1)
+	if rctx.Err() == nil {
+		go func() {
+			err := t.attempt(rctx, e)
+			t.mux.Lock()
+			t.onExecuteErr = err
+			t.mux.Unlock()
+			finished <- true
+			close(finished)
+		}()
+	} else {
+		t.tb.release()
+	}
 	select {
 	case <-rctx.Done():
-		t.log(t.id, "context deadline exceeded after ", t.lifeSpan.Seconds(), " second")
-		t.taskErr = errors.New("context deadline exceeded")
-		if err := e.OnTaskExhausted(rctx, t.id, t.data); err != nil {
-			t.taskErr = t.err(errOnTaskExhausted, err)
-		}
-		shouldCleanup = true
+		t.exhaust(rctx, e)
 	case <-finished:
 		t.log(t.id, "finished executed")
+		t.taskErr = nil
+		if t.onExecuteErr == nil && t.forward != nil {
+			res := t.result
+			if res == nil {
+				res = t.data
+			}
+			if err := t.forward(ctx, t.id, res); err != nil {
+				t.onExecuteErr = fmt.Errorf("unable to forward to next stage, err=%s", err.Error())
+			}
+		}
+		t.setState(StateFinishing)
+		final := StateFinished
 		if t.onExecuteErr != nil {
+			final = StateFailed
 			t.log(t.id, "executed with error=", t.onExecuteErr.Error(), " run on error event")
 			t.taskErr = t.err(errOnExecute, t.onExecuteErr)
-			if err := e.OnExecuteError(rctx, t.id, t.data, t.taskErr); err != nil {
+			var pe *PanicError
+			if errors.As(t.onExecuteErr, &pe) {
+				if err := t.onPanic(rctx, e, pe); err != nil {
+					t.taskErr = t.err(errOnExecuteErr, err)
+				}
+			} else if err := e.OnExecuteError(rctx, t.id, t.data, t.taskErr); err != nil {
 				t.taskErr = t.err(errOnExecuteErr, err)
 			}
 		} else {
@@ -116,19 +426,27 @@ func (t *taskImpl) run(ctx context.Context, e Executor) {
 				t.taskErr = t.err(errOnFinish, err)
 			}
 		}
-		shouldCleanup = true
+		t.setState(final)
 	case <-t.baseTask.signalPanic:
+		t.setState(StateRescued)
 		t.taskErr = e.OnPanic(ctx, t.id, t.data)
-		t.tb.panic(true)
-		shouldCleanup = true
+		return runRescued
 	case <-t.baseTask.signalQuit:
+		t.setState(StateDrained)
 		t.log(t.id, "signal terminated detected")
-		t.baseTask.isQuit = true
+		return runQuit
 	}
-	if shouldCleanup {
-		t.drain(rctx, false)
+	return runDone
+}
+
+//exhaust runs OnTaskExhausted once the life span of the task is over
+func (t *taskImpl) exhaust(ctx context.Context, e Executor) {
+	t.setState(StateExhausted)
+	t.log(t.id, "context deadline exceeded after ", t.lifeSpan.Seconds(), " second")
+	t.taskErr = errors.New("context deadline exceeded")
+	if err := e.OnTaskExhausted(ctx, t.id, t.data); err != nil {
+		t.taskErr = t.err(errOnTaskExhausted, err)
 	}
-	return
 }
 
 func (t *taskImpl) drain(ctx context.Context, quitting bool) error {
@@ -136,23 +454,202 @@ func (t *taskImpl) drain(ctx context.Context, quitting bool) error {
 		return errors.New("unable to drain task map already empty")
 	}
 	if quitting {
-		t.signalQuit <- true
+		t.quit()
+		t.unschedule()
+		t.setState(StateDrained)
 	}
 	err := t.tb.remove(t.id)
 	if err != nil {
 		return err
 	}
+	t.endOnce.Do(func() {
+		close(t.ended)
+	})
 	t.log(t.id, fmt.Sprintf("draining task %s, current map length=%d", t.id, t.tb.length()))
 	return nil
 }
 
-func (t *taskImpl) rescue(ctx context.Context) error {
-	t.signalPanic <- true
-	return nil
+//stop marks a task which is not running as quit with the given state
+//and cancels its timer, false when the task is already running
+func (t *taskImpl) stop(state TaskState) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.running {
+		return false
+	}
+	t.quit()
+	t.state = state
+	if t.entry != nil {
+		t.tb.unschedule(t.entry)
+		t.entry = nil
+	}
+	return true
+}
+
+//payload gets the task data
+func (t *taskImpl) payload() interface{} {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.data
+}
+
+func (t *taskImpl) setState(s TaskState) {
+	t.mux.Lock()
+	t.state = s
+	t.mux.Unlock()
+}
+
+//outcome gets the result of the task when it leaves the bucket
+func (t *taskImpl) outcome() *TaskResult {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	res := &TaskResult{
+		ID:       t.id,
+		State:    t.state,
+		Result:   t.result,
+		Enqueued: t.enqueued,
+		Started:  t.started,
+		Finished: time.Now(),
+		Attempts: t.attempts,
+	}
+	switch t.state {
+	case StateFailed:
+		res.Err = t.onExecuteErr
+	case StateExhausted:
+		res.Err = context.DeadlineExceeded
+	case StateDrained:
+		res.Err = ErrTaskDrained
+	case StateRescued:
+		res.Err = ErrTaskRescued
+	case StateScheduled:
+		//recurring task which schedule ended before any run
+		res.State = StateFinished
+	}
+	return res
+}
+
+//rescue runs OnPanic right away for a task which is not running,
+//a running task gets the panic signal. It returns false when the task
+//ended before being rescued or the context is done
+func (t *taskImpl) rescue(ctx context.Context) bool {
+	if t.stop(StateRescued) {
+		t.taskErr = t.e.OnPanic(ctx, t.id, t.payload())
+		t.drain(ctx, false)
+		return true
+	}
+	select {
+	case t.signalPanic <- true:
+		//wait for OnPanic of the running task
+		select {
+		case <-t.ended:
+		case <-ctx.Done():
+		}
+		return true
+	case <-t.ended:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+//attempt calls OnExecute and retries it according to the retry policy
+func (t *taskImpl) attempt(ctx *lifeContext, e Executor) error {
+	for n := 1; ; n++ {
+		if n > 1 {
+			if err := t.reacquire(ctx); err != nil {
+				return err
+			}
+		}
+		if t.baseTask.isQuit() {
+			t.tb.release()
+			return nil
+		}
+		t.advance(StateRunning)
+		t.mux.Lock()
+		t.attempts = n
+		if n == 1 {
+			t.started = time.Now()
+		}
+		enqueued, started := t.enqueued, t.started
+		t.mux.Unlock()
+		t.tb.publish(taskEvent(EventStarted, t.id, enqueued, started, n, nil))
+		err := t.call(withAttempt(ctx, n), e)
+		t.tb.release()
+		d, ok := t.retry.next(n, err)
+		if !ok {
+			return err
+		}
+		t.log(t.id, "attempt ", n, " failed with error=", err.Error(), " retry after ", d.String())
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-t.baseTask.signalQuit:
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+//reacquire takes the worker slot again for a retry, the life span
+//stops counting while waiting for the rate limit and the worker,
+//and for Resume when BucketConfig.PauseFreezesLifeSpan is set
+func (t *taskImpl) reacquire(ctx *lifeContext) error {
+	if t.tb.conf().PauseFreezesLifeSpan {
+		ctx.freeze()
+	}
+	_, err := t.tb.waitResume(ctx, t.signalQuit)
+	if err == nil {
+		ctx.freeze()
+		_, err = t.tb.acquire(ctx, t.signalQuit)
+	}
+	deadline := ctx.thaw()
+	t.mux.Lock()
+	t.deadline = deadline
+	t.mux.Unlock()
+	return err
+}
+
+//call runs the executor, keeping the result when it is a ResultExecutor
+func (t *taskImpl) call(ctx context.Context, e Executor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.log(t.id, "recovered from panic=", fmt.Sprint(r))
+			err = Permanent(&PanicError{Value: r, Stack: debug.Stack()})
+		}
+	}()
+	re, ok := e.(ResultExecutor)
+	if !ok {
+		return e.OnExecute(ctx, t.id, t.data)
+	}
+	var res interface{}
+	res, err = re.OnExecuteResult(ctx, t.id, t.data)
+	t.mux.Lock()
+	t.result = res
+	t.mux.Unlock()
+	return err
 }
 
 //##Region: Base Task implementation
 
+//quit closes the quit signal, safe to be called more than once
+func (b *baseTask) quit() {
+	b.quitOnce.Do(func() {
+		close(b.signalQuit)
+	})
+}
+
+func (b *baseTask) isQuit() bool {
+	select {
+	case <-b.signalQuit:
+		return true
+	default:
+		return false
+	}
+}
+
 func (b *baseTask) log(id string, args ...interface{}) {
 	if b.verbose {
 		id := fmt.Sprintf("[process_id:%s]", id)
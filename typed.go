@@ -0,0 +1,167 @@
+package gobucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+//TypedExecutor is the typed version of Executor, the payload is given as T
+type TypedExecutor[T any] interface {
+	OnExecute(ctx context.Context, id string, data T) error                          //perform something
+	OnFinish(ctx context.Context, id string, data T) error                           //clean up
+	OnTaskExhausted(ctx context.Context, id string, data T) error                    //context deadline happens
+	OnExecuteError(ctx context.Context, id string, data T, onExecuteErr error) error //error while executing
+	OnPanic(ctx context.Context, id string, data T) error                            //perform something when panic happens
+}
+
+//TypedBucket works as TaskBucket with typed payload
+type TypedBucket[T any] interface {
+	Fill(ctx context.Context, taskType TaskType, id string, data T) error
+	FillWithOptions(ctx context.Context, id string, data T, opts ...TaskOption) error
+	FillWait(ctx context.Context, id string, data T, opts ...TaskOption) error
+	FillFuture(ctx context.Context, id string, data T, opts ...TaskOption) (*Future, error)
+	Drain(ctx context.Context, id string) error
+	Rescue(ctx context.Context) ([]*RescuedTask, error)
+	//Bucket gets the untyped bucket, i.e: to be registered in a TaskBucketGroup
+	Bucket() TaskBucket
+}
+
+//NewTypedBucket creates new task bucket with typed payload,
+//the payload coming from the peers is decoded as T
+//args:
+//	cfg: configuration of task bucket
+//	executor: the typed executor handler
+//returns:
+//	typed task bucket
+func NewTypedBucket[T any](cfg *BucketConfig, executor TypedExecutor[T]) TypedBucket[T] {
+	tb := newTaskBucket(cfg, &typedExecutor[T]{e: executor})
+	tb.decoder = func(raw []byte) (interface{}, error) {
+		var v T
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	tb.restore()
+	return &typedBucket[T]{tb: tb}
+}
+
+type typedBucket[T any] struct {
+	tb TaskBucket
+}
+
+func (b *typedBucket[T]) Fill(ctx context.Context, tt TaskType, id string, data T) error {
+	return b.tb.Fill(ctx, tt, id, data)
+}
+
+func (b *typedBucket[T]) FillWithOptions(ctx context.Context, id string, data T, opts ...TaskOption) error {
+	return b.tb.FillWithOptions(ctx, id, data, opts...)
+}
+
+func (b *typedBucket[T]) FillWait(ctx context.Context, id string, data T, opts ...TaskOption) error {
+	return b.tb.FillWait(ctx, id, data, opts...)
+}
+
+func (b *typedBucket[T]) FillFuture(ctx context.Context, id string, data T, opts ...TaskOption) (*Future, error) {
+	return b.tb.FillFuture(ctx, id, data, opts...)
+}
+
+func (b *typedBucket[T]) Drain(ctx context.Context, id string) error {
+	return b.tb.Drain(ctx, id)
+}
+
+func (b *typedBucket[T]) Rescue(ctx context.Context) ([]*RescuedTask, error) {
+	return b.tb.Rescue(ctx)
+}
+
+func (b *typedBucket[T]) Bucket() TaskBucket {
+	return b.tb
+}
+
+//typedExecutor adapts TypedExecutor to Executor
+type typedExecutor[T any] struct {
+	e TypedExecutor[T]
+}
+
+//cast converts the payload, nil is converted to zero value
+func (a *typedExecutor[T]) cast(data interface{}) (T, error) {
+	var zero T
+	if data == nil {
+		return zero, nil
+	}
+	v, ok := data.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected task data type %T, expected %T", data, zero)
+	}
+	return v, nil
+}
+
+func (a *typedExecutor[T]) OnExecute(ctx context.Context, id string, data interface{}) error {
+	v, err := a.cast(data)
+	if err != nil {
+		return Permanent(err)
+	}
+	return a.e.OnExecute(ctx, id, v)
+}
+
+//OnExecuteResult forwards to the typed executor when it produces a result
+func (a *typedExecutor[T]) OnExecuteResult(ctx context.Context, id string, data interface{}) (interface{}, error) {
+	v, err := a.cast(data)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	if re, ok := a.e.(interface {
+		OnExecuteResult(ctx context.Context, id string, data T) (interface{}, error)
+	}); ok {
+		return re.OnExecuteResult(ctx, id, v)
+	}
+	return nil, a.e.OnExecute(ctx, id, v)
+}
+
+func (a *typedExecutor[T]) OnFinish(ctx context.Context, id string, data interface{}) error {
+	v, err := a.cast(data)
+	if err != nil {
+		return err
+	}
+	return a.e.OnFinish(ctx, id, v)
+}
+
+func (a *typedExecutor[T]) OnTaskExhausted(ctx context.Context, id string, data interface{}) error {
+	v, err := a.cast(data)
+	if err != nil {
+		return err
+	}
+	return a.e.OnTaskExhausted(ctx, id, v)
+}
+
+func (a *typedExecutor[T]) OnExecuteError(ctx context.Context, id string, data interface{}, onExecuteErr error) error {
+	v, err := a.cast(data)
+	if err != nil {
+		return err
+	}
+	return a.e.OnExecuteError(ctx, id, v, onExecuteErr)
+}
+
+//OnExecutePanic forwards to the typed executor when it handles the panic,
+//otherwise to OnExecuteError
+func (a *typedExecutor[T]) OnExecutePanic(ctx context.Context, id string, data interface{}, value interface{}, stack []byte) error {
+	v, err := a.cast(data)
+	if err != nil {
+		return err
+	}
+	if pe, ok := a.e.(interface {
+		OnExecutePanic(ctx context.Context, id string, data T, value interface{}, stack []byte) error
+	}); ok {
+		return pe.OnExecutePanic(ctx, id, v, value, stack)
+	}
+	return a.e.OnExecuteError(ctx, id, v, &PanicError{Value: value, Stack: stack})
+}
+
+func (a *typedExecutor[T]) OnPanic(ctx context.Context, id string, data interface{}) error {
+	v, err := a.cast(data)
+	if err != nil {
+		return err
+	}
+	return a.e.OnPanic(ctx, id, v)
+}